@@ -3,19 +3,35 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
 
+	"github.com/bunyk/fasolasi/src/audio"
+	"github.com/bunyk/fasolasi/src/config"
 	"github.com/bunyk/fasolasi/src/game"
+	"github.com/bunyk/fasolasi/src/logging"
+	"github.com/bunyk/fasolasi/src/sheet"
+	"github.com/bunyk/fasolasi/src/transcribe"
 	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/bunyk/fasolasi/src/ui/glwin"
 )
 
+// Replay to watch instead of the main menu, if given
+var replayFile string
+
+// Error of loading the config, shown when the game starts
+var loadErr error
+
+var windowBounds = pixel.R(0, 0, 1024, 768)
+
 func run() {
 	cfg := pixelgl.WindowConfig{
 		Title:     "FaSoLaSi",
-		Bounds:    pixel.R(0, 0, 1024, 768),
+		Bounds:    windowBounds,
 		VSync:     true,
 		Resizable: true,
 	}
@@ -24,22 +40,46 @@ func run() {
 		log.Fatal(err)
 	}
 	win.SetSmooth(true)
+	window := glwin.New(win)
 
-	// FPS tracking
+	if err := audio.Init(config.SpeakerSampleRate, config.SpeakerLatency); err != nil {
+		slog.Warn("Sound is disabled", "err", err)
+	}
+	audio.SetVolume(config.CurrentProfile.Volume, config.CurrentProfile.Mute)
+
+	// FPS tracking, also shown in the game by F3
 	frames := 0
 	second := time.Tick(time.Second * 5)
 
 	var currentScene ui.Scene
 	currentScene = &game.MainMenu{}
+	if replayFile != "" {
+		r, err := game.LoadReplay(replayFile)
+		if err != nil {
+			currentScene = game.NewErrorScene("Failed to load the replay", err, nil)
+		} else {
+			currentScene = game.NewReplay(r)
+		}
+	}
+	if err := game.SetupVoice(); err != nil {
+		currentScene = game.NewErrorScene("Failed to turn on voice control", err, currentScene)
+	}
+	if loadErr != nil {
+		currentScene = game.NewErrorScene("Failed to load the config", loadErr, currentScene)
+	}
 	// currentScene = game.NewSession("A short one.txt", "challenge", 20)
 
 	for !win.Closed() {
-		currentScene = currentScene.Loop(win)
+		next := currentScene.Loop(window)
+		if next != currentScene { // new scene starts with focus on its first widget
+			ui.ResetFocus()
+		}
+		currentScene = next
 
 		frames++
 		select {
 		case <-second:
-			fmt.Printf("FPS: %d\n", frames/5)
+			slog.Debug("Frames per second", "fps", frames/5)
 			frames = 0
 		default:
 		}
@@ -47,5 +87,65 @@ func run() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "transcribe" {
+		if err := transcribe.Command(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		if err := config.LintCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	// before loading the config, so its warnings are logged too
+	level := slog.LevelInfo
+	if name := os.Getenv("FASOLASI_LOG"); name != "" {
+		var err error
+		if level, err = logging.ParseLevel(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Unknown log level %#v, should be debug, info, warn or error\n", name)
+			os.Exit(1)
+		}
+	}
+	logFile, err := logging.Setup(config.LogFileName, level, os.Stderr)
+	if err != nil {
+		slog.Error("Failed to open log file", "err", err)
+	} else {
+		defer logFile.Close()
+	}
+	loadErr = config.Load()
+	if loadErr != nil && len(os.Args) > 1 && (os.Args[1] == "sheet" || os.Args[1] == "video") {
+		fmt.Fprintln(os.Stderr, loadErr)
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sheet" {
+		if err := sheet.Command(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "video" {
+		if len(os.Args) != 4 {
+			fmt.Fprintln(os.Stderr, "Usage: fasolasi video replay.yaml frames/")
+			os.Exit(1)
+		}
+		r, err := game.LoadReplay(os.Args[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+		written, err := game.ExportVideo(r, os.Args[3], windowBounds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Saved %d frames to %s\n", written, os.Args[3])
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "replay" {
+		replayFile = os.Args[2]
+	}
 	pixelgl.Run(run)
 }
@@ -0,0 +1,94 @@
+// Package audio plays sounds of the game through the speakers
+package audio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+	"github.com/faiface/beep/speaker"
+)
+
+var sampleRate beep.SampleRate
+var mixer = &beep.Mixer{}
+var control = &beep.Ctrl{Streamer: mixer}
+var volume = &effects.Volume{Streamer: control, Base: 2}
+var played = &tap{Streamer: volume}
+var initialized = false
+
+// Keeps the latest samples played, so microphone could tell them from the player
+type tap struct {
+	beep.Streamer
+	mu     sync.Mutex
+	recent [][2]float64 // ring buffer
+	pos    int          // where the next sample goes
+}
+
+func (t *tap) Stream(samples [][2]float64) (int, bool) {
+	n, ok := t.Streamer.Stream(samples)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range samples[:n] {
+		t.recent[t.pos] = s
+		t.pos = (t.pos + 1) % len(t.recent)
+	}
+	return n, ok
+}
+
+// Init opens audio output. Without it other functions of the package do nothing.
+func Init(rate int, latency time.Duration) error {
+	sampleRate = beep.SampleRate(rate)
+	if err := speaker.Init(sampleRate, sampleRate.N(latency)); err != nil {
+		return err
+	}
+	played.recent = make([][2]float64, sampleRate.N(time.Second))
+	speaker.Play(played)
+	initialized = true
+	return nil
+}
+
+// SetVolume sets volume of all the sounds, from 0 (silence) to 1 (loudest)
+func SetVolume(v float64, mute bool) {
+	if !initialized {
+		return
+	}
+	speaker.Lock()
+	defer speaker.Unlock()
+	volume.Volume = (v - 1) * 5 // perceived volume is logarithmic, so this makes 0.5 to sound like half of 1
+	volume.Silent = mute || v <= 0
+}
+
+// Play sound together with others
+func Play(s beep.Streamer) {
+	if !initialized {
+		return
+	}
+	speaker.Lock()
+	defer speaker.Unlock()
+	mixer.Add(s)
+}
+
+// Pause or resume all the sounds
+func Pause(paused bool) {
+	if !initialized {
+		return
+	}
+	speaker.Lock()
+	defer speaker.Unlock()
+	control.Paused = paused
+}
+
+// Recent fills samples with the latest sound played, at the rate given to Init.
+// Silence when audio is not initialized.
+func Recent(samples [][2]float64) {
+	if !initialized {
+		clear(samples)
+		return
+	}
+	played.mu.Lock()
+	defer played.mu.Unlock()
+	for i := range samples {
+		samples[i] = played.recent[(played.pos-len(samples)+i+len(played.recent)*2)%len(played.recent)]
+	}
+}
@@ -0,0 +1,35 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const clickLength = 30 * time.Millisecond
+
+// Click is a short burst of noise. It has no pitch, so it is not confused with notes played.
+// Accented click is louder.
+func Click(accent bool) beep.Streamer {
+	length := sampleRate.N(clickLength)
+	decay := float64(length) / 5
+	amplitude := 0.4
+	if accent {
+		amplitude = 1.0
+	}
+	noise := rand.New(rand.NewSource(1))
+	i := 0
+	return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		if i >= length {
+			return 0, false
+		}
+		for n = 0; n < len(samples) && i < length; n++ {
+			v := amplitude * math.Exp(-float64(i)/decay) * (noise.Float64()*2 - 1)
+			samples[n] = [2]float64{v, v}
+			i++
+		}
+		return n, true
+	})
+}
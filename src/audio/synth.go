@@ -0,0 +1,140 @@
+package audio
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Clock is the time of the song in seconds, shared between the game and the audio output
+type Clock struct {
+	bits atomic.Uint64
+}
+
+func (c *Clock) Set(t float64) {
+	c.bits.Store(math.Float64bits(t))
+}
+
+func (c *Clock) Get() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Voice is a sequence of notes played by the synthesizer, one at a time
+type Voice struct {
+	Notes  []notes.SongNote
+	Octave int     // shift of pitches, in octaves
+	Volume float64 // from 0 to 1
+}
+
+// Pitch frequency at time t, or -1 if nothing is played.
+// cursor is the index of the note where search starts, it is updated for the next time.
+func (v Voice) frequencyAt(t float64, cursor *int) float64 {
+	if *cursor >= len(v.Notes) || v.Notes[*cursor].Time > t { // time went back, search from the start
+		*cursor = 0
+	}
+	for *cursor < len(v.Notes) && v.Notes[*cursor].End() <= t {
+		*cursor++
+	}
+	if *cursor >= len(v.Notes) || v.Notes[*cursor].Time > t || v.Notes[*cursor].Pitch.Frequency < 0 {
+		return -1
+	}
+	return v.Notes[*cursor].Pitch.Frequency * math.Pow(2, float64(v.Octave))
+}
+
+const wavetableSize = 1024
+const envelopeTime = 0.01 // seconds for the note to fade in and out
+
+// Relative amplitudes of harmonics. Recorder has a strong fundamental and weak overtones.
+var harmonics = []float64{1.0, 0.2, 0.1, 0.03}
+
+var wavetable = func() []float64 {
+	table := make([]float64, wavetableSize)
+	sum := 0.0
+	for _, a := range harmonics {
+		sum += a
+	}
+	for i := range table {
+		for h, a := range harmonics {
+			table[i] += a / sum * math.Sin(2*math.Pi*float64((h+1)*i)/wavetableSize)
+		}
+	}
+	return table
+}()
+
+// Synth plays voices with the sound similar to the recorder.
+// If Clock is given, it plays notes at the time of the clock, otherwise - from the beginning, until the end.
+type Synth struct {
+	Voices  []Voice
+	Clock   *Clock
+	time    float64
+	cursors []int
+	phases  []float64 // position in the wavetable, from 0 to 1
+	freqs   []float64 // last frequency of every voice, to let it fade out
+	levels  []float64 // current amplitude of every voice
+	end     float64
+	stopped atomic.Bool
+}
+
+func NewSynth(voices []Voice, clock *Clock) *Synth {
+	s := &Synth{
+		Voices:  voices,
+		Clock:   clock,
+		cursors: make([]int, len(voices)),
+		phases:  make([]float64, len(voices)),
+		freqs:   make([]float64, len(voices)),
+		levels:  make([]float64, len(voices)),
+	}
+	for _, v := range voices {
+		if len(v.Notes) > 0 && v.Notes[len(v.Notes)-1].End() > s.end {
+			s.end = v.Notes[len(v.Notes)-1].End()
+		}
+	}
+	return s
+}
+
+// Stop playing. Synth could not be played again after that.
+func (s *Synth) Stop() {
+	s.stopped.Store(true)
+}
+
+// Finished is true when synth was stopped, or played all the notes
+func (s *Synth) Finished() bool {
+	return s.stopped.Load()
+}
+
+func (s *Synth) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.stopped.Load() {
+		return 0, false
+	}
+	t := s.time
+	if s.Clock != nil {
+		t = s.Clock.Get()
+	} else if t > s.end+envelopeTime {
+		s.Stop()
+		return 0, false
+	}
+	dt := 1 / float64(sampleRate)
+	smoothing := 1 - math.Exp(-dt/envelopeTime)
+	for i := range samples {
+		value := 0.0
+		for vi, voice := range s.Voices {
+			target := 0.0
+			if f := voice.frequencyAt(t, &s.cursors[vi]); f > 0 {
+				s.freqs[vi] = f
+				target = voice.Volume
+			}
+			s.levels[vi] += (target - s.levels[vi]) * smoothing
+			s.phases[vi] = math.Mod(s.phases[vi]+s.freqs[vi]*dt, 1)
+			value += s.levels[vi] * wavetable[int(s.phases[vi]*wavetableSize)%wavetableSize]
+		}
+		samples[i] = [2]float64{value, value}
+		t += dt
+	}
+	s.time = t
+	return len(samples), true
+}
+
+func (s *Synth) Err() error {
+	return nil
+}
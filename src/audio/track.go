@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/speaker"
+	"github.com/faiface/beep/vorbis"
+	"github.com/faiface/beep/wav"
+)
+
+// How far could track go from the clock, before it is moved back in sync, in seconds
+const trackTolerance = 0.1
+
+// Track plays audio file in sync with the clock
+type Track struct {
+	Clock     *Clock
+	start     float64 // position in the file, in seconds, when clock is at zero
+	speed     float64 // seconds of file played per second of clock
+	file      beep.StreamSeekCloser
+	format    beep.Format
+	resampled beep.Streamer
+	stopped   atomic.Bool
+}
+
+// OpenTrack decodes WAV, MP3 or OGG file.
+// Position of the file at the time firstNote of the clock is offset,
+// and it is played with the given speed, which changes its pitch.
+func OpenTrack(path string, offset, firstNote, speed float64, clock *Clock) (*Track, error) {
+	if !initialized {
+		return nil, fmt.Errorf("sound is disabled")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var decode func(*os.File) (beep.StreamSeekCloser, beep.Format, error)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		decode = func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) { return wav.Decode(f) }
+	case ".mp3":
+		decode = func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) { return mp3.Decode(f) }
+	case ".ogg":
+		decode = func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) { return vorbis.Decode(f) }
+	default:
+		f.Close()
+		return nil, fmt.Errorf("unsupported audio format of %s, use WAV, MP3 or OGG", path)
+	}
+	file, format, err := decode(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return &Track{
+		Clock:     clock,
+		start:     offset - firstNote*speed,
+		speed:     speed,
+		file:      file,
+		format:    format,
+		resampled: beep.ResampleRatio(4, float64(format.SampleRate)/float64(sampleRate)*speed, file),
+	}, nil
+}
+
+// Stop playing and close the file
+func (t *Track) Stop() {
+	if !t.stopped.Swap(true) {
+		speaker.Lock()
+		defer speaker.Unlock()
+		t.file.Close()
+	}
+}
+
+func (t *Track) Stream(samples [][2]float64) (n int, ok bool) {
+	if t.stopped.Load() {
+		return 0, false
+	}
+	position := t.start + t.Clock.Get()*t.speed
+	if position < 0 { // track did not start yet
+		for i := range samples {
+			samples[i] = [2]float64{}
+		}
+		return len(samples), true
+	}
+	if math.Abs(t.format.SampleRate.D(t.file.Position()).Seconds()-position) > trackTolerance {
+		sample := t.format.SampleRate.N(time.Duration(position * float64(time.Second)))
+		if sample >= t.file.Len() {
+			return 0, false
+		}
+		if err := t.file.Seek(sample); err != nil {
+			return 0, false
+		}
+	}
+	return t.resampled.Stream(samples)
+}
+
+func (t *Track) Err() error {
+	return t.file.Err()
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Orders of the song list
+const (
+	SortName       = "name"
+	SortDifficulty = "difficulty"
+	SortLastPlayed = "last played"
+	SortBestScore  = "best score"
+)
+
+var SongSorts = []string{SortName, SortDifficulty, SortLastPlayed, SortBestScore}
+
+// SongStats are what player may want to know about the song before playing it
+type SongStats struct {
+	Notes      int         // without pauses
+	Low, High  notes.Pitch // lowest and highest notes
+	Duration   float64     // in seconds, at the target tempo
+	Difficulty float64     // rough, to compare songs: notes per second, more for half tones and wide range
+}
+
+// Stats of the song, as it is transposed
+func (s Song) Stats() (SongStats, error) {
+	song, err := s.ParseNotes(240.0 / float64(s.TargetBPM()))
+	if err != nil {
+		return SongStats{}, err
+	}
+	var st SongStats
+	half := 0
+	for _, n := range song {
+		if n.Pitch.Name == notes.Pause.Name {
+			continue
+		}
+		if st.Notes == 0 || n.Pitch.Frequency < st.Low.Frequency {
+			st.Low = n.Pitch
+		}
+		if st.Notes == 0 || n.Pitch.Frequency > st.High.Frequency {
+			st.High = n.Pitch
+		}
+		if n.Pitch.IsHalf {
+			half++
+		}
+		st.Notes++
+	}
+	if len(song) > 0 {
+		st.Duration = song[len(song)-1].End() - song[0].Time
+	}
+	if st.Notes > 0 && st.Duration > 0 {
+		octaves := math.Log2(st.High.Frequency / st.Low.Frequency)
+		st.Difficulty = float64(st.Notes) / st.Duration * (1 + float64(half)/float64(st.Notes)) * (1 + octaves)
+	}
+	return st, nil
+}
+
+// SongEntry is a song of the list, with what the list shows about it
+type SongEntry struct {
+	ID int // index in Songs
+	Song
+	Stats   SongStats
+	Err     error // of reading the notes, then Stats are empty
+	History SongHistory
+}
+
+// SongEntries for all the songs, with their history from the profile
+func SongEntries() []SongEntry {
+	entries := make([]SongEntry, len(Songs))
+	for i, song := range Songs {
+		entries[i] = SongEntry{ID: i, Song: song, History: CurrentProfile.History[song.Name]}
+		entries[i].Stats, entries[i].Err = song.Stats()
+	}
+	return entries
+}
+
+// SongTags used by the songs, sorted
+func SongTags(entries []SongEntry) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, e := range entries {
+		for _, t := range e.Tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// FilterSongs that have every word of the search in their name or tags, and all the tags, in the order.
+// Songs never played go to the end when sorted by the last played or the best score.
+func FilterSongs(entries []SongEntry, search string, tags []string, order string) []SongEntry {
+	words := strings.Fields(strings.ToLower(search))
+	var found []SongEntry
+	for _, e := range entries {
+		if e.matches(words, tags) {
+			found = append(found, e)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch order {
+		case SortDifficulty:
+			if a.Stats.Difficulty != b.Stats.Difficulty {
+				return a.Stats.Difficulty < b.Stats.Difficulty
+			}
+		case SortLastPlayed:
+			if !a.History.LastPlayed.Equal(b.History.LastPlayed) {
+				return a.History.LastPlayed.After(b.History.LastPlayed)
+			}
+		case SortBestScore:
+			if a.History.Best != b.History.Best {
+				return a.History.Best > b.History.Best
+			}
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	return found
+}
+
+func (e SongEntry) matches(words, tags []string) bool {
+	text := strings.ToLower(e.Name + " " + strings.Join(e.Tags, " "))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	for _, t := range tags {
+		if !e.hasTag(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e SongEntry) hasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
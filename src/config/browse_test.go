@@ -0,0 +1,74 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSongStats(t *testing.T) {
+	st, err := Song{Name: "Test", Notes: "c d e p f", Tempo: 120}.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, st.Notes)
+	assert.Equal(t, "c", st.Low.Name)
+	assert.Equal(t, "f", st.High.Name)
+	assert.InDelta(t, 2.5, st.Duration, 0.2) // last note is shorter by the breath
+
+	faster, _ := Song{Name: "Test", Notes: "c d e p f", Tempo: 240}.Stats()
+	wider, _ := Song{Name: "Test", Notes: "c d e p c''", Tempo: 120}.Stats()
+	sharper, _ := Song{Name: "Test", Notes: "cis d e p f", Tempo: 120}.Stats()
+	for _, harder := range []SongStats{faster, wider, sharper} {
+		assert.True(t, harder.Difficulty > st.Difficulty, "%v should be harder than %v", harder, st)
+	}
+
+	_, err = Song{Name: "Test", Notes: "c d'''"}.Stats()
+	assert.Error(t, err)
+}
+
+func TestFilterSongs(t *testing.T) {
+	entries := []SongEntry{
+		{ID: 0, Song: Song{Name: "Ode to joy", Tags: []string{"classic"}}, Stats: SongStats{Difficulty: 2}},
+		{ID: 1, Song: Song{Name: "Jingle bells", Tags: []string{"christmas", "folk"}}, Stats: SongStats{Difficulty: 1},
+			History: SongHistory{LastPlayed: day(1), Best: 500}},
+		{ID: 2, Song: Song{Name: "Greensleeves", Tags: []string{"folk"}}, Stats: SongStats{Difficulty: 3},
+			History: SongHistory{LastPlayed: day(2), Best: 200}},
+		{ID: 3, Song: Song{Name: "amazing grace"}, Stats: SongStats{Difficulty: 1}},
+	}
+	ids := func(found []SongEntry) (ids []int) {
+		for _, e := range found {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int{3, 2, 1, 0}, ids(FilterSongs(entries, "", nil, SortName)))
+	assert.Equal(t, []int{3, 1, 0, 2}, ids(FilterSongs(entries, "", nil, SortDifficulty)))
+	assert.Equal(t, []int{2, 1, 3, 0}, ids(FilterSongs(entries, "", nil, SortLastPlayed)))
+	assert.Equal(t, []int{1, 2, 3, 0}, ids(FilterSongs(entries, "", nil, SortBestScore)))
+
+	assert.Equal(t, []int{2, 1}, ids(FilterSongs(entries, "", []string{"folk"}, SortName)))
+	assert.Equal(t, []int{1}, ids(FilterSongs(entries, "", []string{"folk", "christmas"}, SortName)))
+	assert.Equal(t, []int{2, 1}, ids(FilterSongs(entries, "FOLK", nil, SortName)), "tags are searched too")
+	assert.Equal(t, []int{1}, ids(FilterSongs(entries, "bel jin", nil, SortName)))
+	assert.Empty(t, FilterSongs(entries, "waltz", nil, SortName))
+
+	assert.Equal(t, []string{"christmas", "classic", "folk"}, SongTags(entries))
+}
+
+func TestPlayed(t *testing.T) {
+	var p Profile
+	song := Song{Name: "Test", Tempo: 100}
+	p.Played(song, Run{Mode: "challenge", BPM: 100, Difficulty: "normal", Finished: true, Score: 300, Grade: "B"}, day(1))
+	p.Played(song, Run{Mode: "training", BPM: 100, Difficulty: "normal", Finished: true, Score: 900}, day(2))
+	p.Played(song, Run{Mode: "challenge", BPM: 100, Difficulty: "normal", Finished: true, Score: 100, Grade: "C"}, day(3))
+	p.Played(song, Run{Mode: "challenge", BPM: 60, Difficulty: "normal", Finished: true, Score: 900, Grade: "S"}, day(4))
+	p.Played(song, Run{Mode: "challenge", BPM: 100, Difficulty: "normal", Finished: false, Score: 900, Grade: "S"}, day(5))
+	assert.Equal(t, SongHistory{LastPlayed: day(5), Best: 300, Grade: "B", Difficulty: "normal"}, p.History["Test"])
+
+	p.Played(song, Run{Mode: "challenge", BPM: 100, Difficulty: "easy", Finished: true, Score: 900, Grade: "S"}, day(6))
+	assert.Equal(t, 300, p.History["Test"].Best, "easier difficulty is not better")
+	p.Played(song, Run{Mode: "challenge", BPM: 120, Difficulty: "hard", Finished: true, Score: 200, Grade: "C"}, day(7))
+	assert.Equal(t, SongHistory{LastPlayed: day(7), Best: 200, Grade: "C", Difficulty: "hard"}, p.History["Test"])
+}
+
+func day(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
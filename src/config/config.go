@@ -2,8 +2,10 @@ package config
 
 import (
 	_ "embed"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"golang.org/x/image/colornames"
 
@@ -16,6 +18,25 @@ import (
 const MicrophoneSampleRate = 188200
 const MicrophoneBufferLength = 11025 / 2
 
+// Output tuning
+const SpeakerSampleRate = 44100
+const SpeakerLatency = 50 * time.Millisecond
+
+// Synthesizer
+const MelodyVolume = 0.6
+const AccompanimentVolume = 0.4
+const AccompanimentOctave = -2 // Play accompaniment lower, so it is not heard as notes played by player
+
+// How long microphone does not update pitch after metronome click, so it is not heard as a note.
+// Enough for the click to be played, to be heard, and for microphone buffer to be filled again.
+const ClickHoldTime = 150 * time.Millisecond
+
+// Microphone learns how loud the game is heard until that many seconds before the first note, when player could start to play
+const CalibrationMargin = 0.5
+
+// How long the note should be played to press the button with voice control, so short noises don't press anything
+const VoiceHoldTime = 400 * time.Millisecond
+
 const NoteSPS = 0.15 // Note speed in screens per second
 const BlackNoteWidth = 0.3
 const WhiteNoteWidth = 0.5
@@ -27,15 +48,52 @@ const TimeLinePositionLatency = TimeLinePosition + NoteSPS*(MicrophoneBufferLeng
 
 const NoteRadius = 40
 
-const BreathInterval = 0.05 // Pause between notes
+const BreathInterval = 0.05    // Pause between notes, in whole notes
+const MaxBreathFraction = 0.25 // But not longer than this part of the note
 const TimeBeforeFirstNote = 2.0
 
+// Difficulty defines how strict is the scoring of the challenge mode
+type Difficulty struct {
+	Name    string
+	Perfect float64    // Hit windows, in seconds from the start or the end of the note
+	Great   float64    // ...
+	Good    float64    // Anything further than that is a miss
+	Grades  [3]float64 // Minimal fractions of the maximal song score for S, A and B grades, lower is C
+}
+
+var Difficulties = []Difficulty{
+	{"easy", 0.1, 0.2, 0.35, [3]float64{0.85, 0.65, 0.45}},
+	{"normal", 0.06, 0.12, 0.25, [3]float64{0.9, 0.75, 0.55}},
+	{"hard", 0.03, 0.07, 0.15, [3]float64{0.95, 0.85, 0.7}},
+}
+
+const DefaultDifficulty = 1 // index in Difficulties
+
+const TrainingReactionTime = 0.3 // Seconds to start the next note in training mode, that are not counted as extra time
+const WrongNotePenalty = 1.0     // Seconds of extra time added in training mode for each wrong note
+const MinBPM = 20
+const MaxBPM = 240
+const TempoStep = 5         // in bpm, for the tempo picker
+const DefaultTempo = 90     // For songs that do not specify their tempo
+const PracticeTempoStep = 5 // Increase of tempo after each clean pass of the practiced section
+
+// Follow mode
+const FollowMinRate = 0.5   // Slowest and fastest timeline speed relative to the song tempo
+const FollowMaxRate = 1.5   // ...
+const FollowResponse = 2.0  // How fast timeline changes its speed, 1/seconds
+const FollowPhaseGain = 0.5 // How much timeline speeds up or slows down to catch up with the player
+const FollowWaitRate = 0.7  // Speed of the timeline while player is late to start the note
+
+const ComboStep = 10    // Score multiplier grows by one every ComboStep hits in a row
+const MaxMultiplier = 4 // but not above this
+
 var BackgroundColor = colornames.Antiquewhite
 
 var ButtonColor = colornames.White
 var ButtonTextColor = colornames.Black
 var ButtonShadowColor = colornames.Darkgray
 var SelectionColor = colornames.Lightblue
+var FocusColor = colornames.Royalblue
 
 const MenuButtonWidth = 500.0
 const MenuButtonHeight = 50.0
@@ -52,12 +110,23 @@ var defaultConfigData []byte
 
 const ConfigFileName = "config.yaml"
 
+// Errors and other messages of the game are written there, to find out what went wrong
+const LogFileName = "fasolasi.log"
+
+// Directory where sessions are recorded, to watch them again
+const ReplaysDir = "replays"
+
 type configFile struct {
 	Songs           []Song `yaml:"songs"`
 	BackgroundColor string `yaml:"background_color"`
 }
 
-func init() {
+// Load songs from the config file, and the profile of the player.
+// Called by the game at start, and not in init, so packages that need only constants could be tested without files.
+// On error the game could still start, with songs and settings that were read.
+func Load() error {
+	loadProfile()
+
 	// read config
 	data, err := os.ReadFile(ConfigFileName)
 	if err != nil {
@@ -66,22 +135,26 @@ func init() {
 			data = defaultConfigData
 			err := os.WriteFile(ConfigFileName, data, 0644)
 			if err != nil {
-				log.Printf("Failed to create default config file %s", ConfigFileName)
+				slog.Warn("Failed to create default config file", "file", ConfigFileName, "err", err)
 			}
 		} else {
-			log.Fatal(err)
+			return err
 		}
 	}
 	var cf configFile
 	err = yaml.Unmarshal(data, &cf)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("Failed to parse %s: %w", ConfigFileName, err)
 	}
 
 	// now copy values to package variables
 	Songs = cf.Songs
-	BackgroundColor, err = parseColor(cf.BackgroundColor)
-	if err != nil {
-		log.Fatalf("failed to parse background_color %s", err)
+	if cf.BackgroundColor == "" {
+		return nil
+	}
+	if BackgroundColor, err = parseColor(cf.BackgroundColor); err != nil {
+		BackgroundColor = colornames.Antiquewhite
+		return fmt.Errorf("Failed to parse background_color of %s: %w", ConfigFileName, err)
 	}
+	return nil
 }
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/notes"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem found in the config file
+type Problem struct {
+	Line, Column int
+	Message      string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// Whole token of the song, noteRe finds notes also in the garbage around them
+var tokenRe = regexp.MustCompile(`^([a-z']+)(\d+)?(\.?)$`)
+
+// Note values that could be written
+var noteValues = map[int]bool{1: true, 2: true, 4: true, 8: true, 16: true, 32: true, 64: true}
+
+// Lint checks songs of the config file, and returns problems with their positions.
+// Error is returned only when the file is not a valid YAML.
+func Lint(data []byte) ([]Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config is not a mapping")
+	}
+	l := linter{source: string(data)}
+	songs := mappingValue(doc.Content[0], "songs")
+	if songs == nil || songs.Kind != yaml.SequenceNode {
+		l.report(doc.Content[0], "no songs")
+		return l.problems, nil
+	}
+	names := make(map[string]*yaml.Node)
+	for _, node := range songs.Content {
+		var song Song
+		if err := node.Decode(&song); err != nil {
+			l.report(node, err.Error())
+			continue
+		}
+		if first, ok := names[song.Name]; ok {
+			l.report(mappingValue(node, "name"), fmt.Sprintf("song %#v is already defined on line %d", song.Name, first.Line))
+		} else {
+			names[song.Name] = node
+		}
+		l.song(song, node)
+	}
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i], l.problems[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return l.problems, nil
+}
+
+type linter struct {
+	source   string
+	problems []Problem
+}
+
+func (l *linter) report(node *yaml.Node, message string) {
+	l.problems = append(l.problems, Problem{Line: node.Line, Column: node.Column, Message: message})
+}
+
+func (l *linter) reportAt(offset int, message string) {
+	line := strings.Count(l.source[:offset], "\n") + 1
+	column := offset - strings.LastIndex(l.source[:offset], "\n")
+	l.problems = append(l.problems, Problem{Line: line, Column: column, Message: message})
+}
+
+func (l *linter) song(song Song, node *yaml.Node) {
+	if song.Name == "" {
+		l.report(node, "song has no name")
+	}
+	if song.Tempo != 0 && (song.Tempo < MinBPM || song.Tempo > MaxBPM) {
+		l.report(mappingValue(node, "tempo"), fmt.Sprintf("tempo %d bpm is out of the range from %d to %d", song.Tempo, MinBPM, MaxBPM))
+	}
+	fifths, err := song.Fifths()
+	if err != nil {
+		l.report(mappingValue(node, "key"), err.Error())
+	}
+	barLength := 0.0 // in whole notes, 0 when unknown
+	beats, unit, err := song.TimeSignature()
+	if err != nil {
+		l.report(mappingValue(node, "time"), err.Error())
+	} else {
+		barLength = float64(beats) / float64(unit)
+	}
+
+	notesNode := mappingValue(node, "notes")
+	if notesNode == nil {
+		l.report(node, fmt.Sprintf("song %#v has no notes", song.Name))
+		return
+	}
+	melody := l.voice(notesNode, song.Transpose, fifths)
+	if barLength > 0 {
+		if rest := math.Mod(melody, barLength); rest > 1e-9 && barLength-rest > 1e-9 {
+			l.report(notesNode, fmt.Sprintf("notes take %s bars of %d/%d, not a whole number", trimFloat(melody/barLength), beats, unit))
+		}
+	}
+	if voices := mappingValue(node, "accompaniment"); voices != nil {
+		for _, v := range voices.Content {
+			if length := l.voice(v, song.Transpose, fifths); math.Abs(length-melody) > 1e-9 {
+				l.report(v, fmt.Sprintf("voice takes %s whole notes, and the melody %s", trimFloat(length), trimFloat(melody)))
+			}
+		}
+	}
+}
+
+// Check notes of one voice, return its length in whole notes
+func (l *linter) voice(node *yaml.Node, semitones, fifths int) (length float64) {
+	offset := l.offset(node)
+	previous := notes.SongNote{Value: 4}
+	for _, token := range strings.Fields(node.Value) {
+		start := findToken(l.source, offset, token)
+		if start < 0 { // written with escapes, so point to the start of the value
+			start = l.offset(node)
+		} else {
+			offset = start + len(token)
+		}
+		parts := tokenRe.FindStringSubmatch(token)
+		if parts == nil {
+			var read []string
+			for _, m := range noteRe.FindAllStringSubmatch(token, -1) {
+				read = append(read, m[1]+m[2])
+			}
+			if len(read) == 0 {
+				l.reportAt(start, fmt.Sprintf("%#v is not a note, and is skipped", token))
+			} else {
+				l.reportAt(start, fmt.Sprintf("%#v is not a note, it is read as %s", token, strings.Join(read, " ")))
+			}
+			continue
+		}
+		n, err := noteFromMatch(parts, previous, 1)
+		if err != nil {
+			l.reportAt(start, err.Error())
+			continue
+		}
+		if !noteValues[n.Value] {
+			l.reportAt(start, fmt.Sprintf("%#v has duration 1/%d, it should be 1, 2, 4, 8, 16, 32 or 64", token, n.Value))
+			continue
+		}
+		previous = n
+		length += n.Duration
+		if n.Spelling.Letter == 0 {
+			continue
+		}
+		sp := n.Spelling.Transpose(semitones, fifths)
+		if _, ok := sp.Pitch(); ok {
+			continue
+		}
+		if semitones != 0 {
+			l.reportAt(start, fmt.Sprintf("%#v transposed to %s is out of range, it should be from c to c''", token, sp.Name()))
+		} else {
+			l.reportAt(start, fmt.Sprintf("%#v is out of range, it should be from c to c''", token))
+		}
+	}
+	return length
+}
+
+// Byte offset of the node in the source
+func (l *linter) offset(node *yaml.Node) int {
+	offset := 0
+	for line := 1; line < node.Line; line++ {
+		next := strings.IndexByte(l.source[offset:], '\n')
+		if next < 0 {
+			return offset
+		}
+		offset += next + 1
+	}
+	return offset + node.Column - 1
+}
+
+// Offset of the token in the source, not as a part of a longer word. -1 if not found.
+func findToken(source string, from int, token string) int {
+	for from < len(source) {
+		i := strings.Index(source[from:], token)
+		if i < 0 {
+			return -1
+		}
+		start, end := from+i, from+i+len(token)
+		if (start == 0 || isSpace(source[start-1])) && (end == len(source) || isSpace(source[end])) {
+			return start
+		}
+		from = end
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return strings.IndexByte(" \t\r\n\"", c) >= 0
+}
+
+// Value of the key in the YAML mapping, nil if there is none
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func trimFloat(f float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", f), "0"), ".")
+}
+
+// LintCommand checks the config file given in args, or config.yaml by default:
+// fasolasi lint [config.yaml]
+// Returns error when there are problems, after printing them to out.
+func LintCommand(args []string, out io.Writer) error {
+	path := ConfigFileName
+	if len(args) > 1 {
+		return fmt.Errorf("Usage: fasolasi lint [config.yaml]")
+	}
+	if len(args) == 1 {
+		path = args[0]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	problems, err := Lint(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, p := range problems {
+		fmt.Fprintf(out, "%s:%s\n", path, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in %s", len(problems), path)
+	}
+	return nil
+}
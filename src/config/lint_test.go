@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	data := `songs:
+  - name: One
+    notes: "c d h e4,f"
+    tempo: 400
+    time: 3/4
+  - name: One
+    notes: >
+      c4 d e
+      f3 d''' c''
+    accompaniment:
+      - c2
+  - name: Up
+    transpose: 3
+    notes: g' a' b' c''
+  - name: Fine
+    time: 3/4
+    notes: g4 c'2 e'8 d' c'2 # pickup, and the last bar is shorter
+`
+	problems, err := Lint([]byte(data))
+	assert.NoError(t, err)
+	var lines []string
+	for _, p := range problems {
+		lines = append(lines, p.String())
+	}
+	assert.Equal(t, []string{
+		`3:12: notes take 0.667 bars of 3/4, not a whole number`,
+		`3:17: Unknown note: "h"`,
+		`3:19: "e4,f" is not a note, it is read as e4 f`,
+		`4:12: tempo 400 bpm is out of the range from 20 to 240`,
+		`6:11: song "One" is already defined on line 2`,
+		`7:12: notes take 1.25 bars of 4/4, not a whole number`,
+		`9:7: "f3" has duration 1/3, it should be 1, 2, 4, 8, 16, 32 or 64`,
+		`9:10: "d'''" is out of range, it should be from c to c''`,
+		`11:9: voice takes 0.5 whole notes, and the melody 1.25`,
+		`14:18: "b'" transposed to d'' is out of range, it should be from c to c''`,
+		`14:21: "c''" transposed to es'' is out of range, it should be from c to c''`,
+	}, lines)
+
+	_, err = Lint([]byte("songs: [}"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const ProfileFileName = "profile.yaml"
+
+// Things that game remembers about the player between runs
+type Profile struct {
+	Tempos     map[string]int `yaml:"tempos"`     // last used tempo, by song name
+	Difficulty string         `yaml:"difficulty"` // name of the last used difficulty
+	Volume     float64        `yaml:"volume"`     // from 0 to 1
+	Mute       bool           `yaml:"mute"`
+	Metronome  bool           `yaml:"metronome"`
+
+	Accompaniment bool `yaml:"accompaniment"` // play accompaniment of the song during the game
+	Melody        bool `yaml:"melody"`        // play the song itself during the game, better use headphones for that
+	SheetMusic    bool `yaml:"sheet_music"`   // show notes as sheet music instead of the piano roll
+	Spectrogram   bool `yaml:"spectrogram"`   // show frequencies heard behind the notes
+	PitchTrace    bool `yaml:"pitch_trace"`   // show line of the pitch heard over the notes
+
+	Input      string            `yaml:"input"`                 // where the notes come from, one of Inputs
+	MIDIDevice string            `yaml:"midi_device,omitempty"` // like /dev/snd/midiC1D0, the first one found when empty
+	Keys       map[string]string `yaml:"keys,omitempty"`        // note of each key for the keyboard input, like on the piano when empty
+
+	VoiceControl bool `yaml:"voice_control"` // press buttons of menus by playing their notes
+
+	SongSort string                 `yaml:"song_sort,omitempty"` // order of the song list, one of SongSorts
+	History  map[string]SongHistory `yaml:"history,omitempty"`   // by song name
+}
+
+// What the player did with the song
+type SongHistory struct {
+	LastPlayed time.Time `yaml:"last_played"`
+	Best       int       `yaml:"best,omitempty"`       // best score in the challenge, played to the end in the tempo of the song
+	Grade      string    `yaml:"grade,omitempty"`      // of the best score
+	Difficulty string    `yaml:"difficulty,omitempty"` // of the best score, which is better on harder ones
+}
+
+// Run of the song, to remember in the history
+type Run struct {
+	Mode       string
+	BPM        int
+	Difficulty string
+	Finished   bool // played to the end, not left in the middle
+	Score      int
+	Grade      string
+}
+
+// Ways to play the game. Keyboard plays over the other ones, so it is there for quick tests with any of them.
+const (
+	InputMicrophone = "microphone"
+	InputKeyboard   = "keyboard"
+	InputMIDI       = "midi"
+)
+
+var Inputs = []string{InputMicrophone, InputKeyboard, InputMIDI}
+
+var CurrentProfile = Profile{
+	Volume:        0.8,
+	Input:         InputMicrophone,
+	Metronome:     true,
+	Accompaniment: true,
+}
+
+// Last used tempo for the song, or half of its tempo when it was not played yet
+func (p Profile) Tempo(song Song) int {
+	if bpm, ok := p.Tempos[song.Name]; ok {
+		return bpm
+	}
+	bpm := song.TargetBPM() / 2 / TempoStep * TempoStep
+	if bpm < MinBPM {
+		return MinBPM
+	}
+	return bpm
+}
+
+func (p *Profile) SetTempo(song Song, bpm int) {
+	if p.Tempos == nil {
+		p.Tempos = make(map[string]int)
+	}
+	p.Tempos[song.Name] = bpm
+}
+
+// Played remembers when the song was played, and the score when it is the best challenge.
+// Challenge left in the middle, or played slower than the song, could not be the best.
+func (p *Profile) Played(song Song, run Run, when time.Time) {
+	if p.History == nil {
+		p.History = make(map[string]SongHistory)
+	}
+	h := p.History[song.Name]
+	h.LastPlayed = when
+	if run.Mode == "challenge" && run.Finished && run.BPM >= song.TargetBPM() {
+		harder, was := difficultyIndex(run.Difficulty), difficultyIndex(h.Difficulty)
+		if harder > was || harder == was && run.Score > h.Best {
+			h.Best, h.Grade, h.Difficulty = run.Score, run.Grade, run.Difficulty
+		}
+	}
+	p.History[song.Name] = h
+}
+
+// Index in Difficulties, or -1 when there is no such
+func difficultyIndex(name string) int {
+	for i, d := range Difficulties {
+		if d.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Index of the last used difficulty in Difficulties
+func (p Profile) DifficultyIndex() int {
+	if i := difficultyIndex(p.Difficulty); i >= 0 {
+		return i
+	}
+	return DefaultDifficulty
+}
+
+func (p Profile) Save() error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ProfileFileName, data, 0644)
+}
+
+func loadProfile() {
+	data, err := os.ReadFile(ProfileFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to read profile", "file", ProfileFileName, "err", err)
+		}
+		return
+	}
+	if err := yaml.Unmarshal(data, &CurrentProfile); err != nil {
+		slog.Warn("Failed to parse profile", "file", ProfileFileName, "err", err)
+	}
+}
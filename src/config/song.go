@@ -1,58 +1,256 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"os"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/bunyk/fasolasi/src/notes"
+	"gopkg.in/yaml.v3"
 )
 
 type Song struct {
 	Name  string `yaml:"name"`
 	Notes string `yaml:"notes"`
+	Tempo int    `yaml:"tempo,omitempty"` // in bpm, optional
+	Key   string `yaml:"key,omitempty"`   // like "g" or "e minor", for the sheet music, C major by default
+	Time  string `yaml:"time,omitempty"`  // time signature, like "3/4", 4/4 by default
+
+	Tags []string `yaml:"tags,omitempty"` // like "folk" or "christmas", to filter the song list
+
+	Transpose int `yaml:"transpose,omitempty"` // semitones to shift all notes by, to fit the range of the flute
+
+	Accompaniment []string      `yaml:"accompaniment,omitempty"` // voices played together with the song, in the same notation
+	BackingTrack  *BackingTrack `yaml:"backing_track,omitempty"`
+}
+
+// Audio file played together with the song
+type BackingTrack struct {
+	File   string  `yaml:"file"`   // path to WAV, MP3 or OGG file
+	Offset float64 `yaml:"offset"` // time in the file when the first note starts, in seconds
+}
+
+// Tempo in which the song should be played
+func (s Song) TargetBPM() int {
+	if s.Tempo > 0 {
+		return s.Tempo
+	}
+	return DefaultTempo
+}
+
+// Beats in the bar, and note value of the beat
+func (s Song) TimeSignature() (beats, unit int, err error) {
+	if s.Time == "" {
+		return 4, 4, nil
+	}
+	if _, err := fmt.Sscanf(s.Time, "%d/%d", &beats, &unit); err != nil || beats <= 0 || unit <= 0 {
+		return 0, 0, fmt.Errorf("Wrong time signature %#v of %s, should be like 3/4", s.Time, s.Name)
+	}
+	return beats, unit, nil
+}
+
+// AddSong appends song to the songs list and to the config file.
+// File is edited as a YAML tree, so comments and formatting of other songs are kept.
+func AddSong(song Song) error {
+	doc, songs, err := loadSongsTree()
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := node.Encode(song); err != nil {
+		return err
+	}
+	songs.Content = append(songs.Content, &node)
+	if err := saveTree(doc); err != nil {
+		return err
+	}
+	Songs = append(Songs, song)
+	return nil
+}
+
+// SetTranspose changes transposition of the song, and saves it to the config file
+func SetTranspose(songID, semitones int) error {
+	doc, songs, err := loadSongsTree()
+	if err != nil {
+		return err
+	}
+	if songID < 0 || songID >= len(Songs) {
+		return fmt.Errorf("There is no song %d", songID)
+	}
+	node := songNode(songs, Songs[songID].Name)
+	if node == nil {
+		return fmt.Errorf("Song %s is not in %s", Songs[songID].Name, ConfigFileName)
+	}
+	found := false
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "transpose" {
+			continue
+		}
+		found = true
+		if semitones == 0 {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		} else {
+			node.Content[i+1].Value = strconv.Itoa(semitones)
+		}
+		break
+	}
+	if !found && semitones != 0 {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "transpose"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(semitones)},
+		)
+	}
+	if err := saveTree(doc); err != nil {
+		return err
+	}
+	Songs[songID].Transpose = semitones
+	return nil
+}
+
+// Node of the song with the name, in the list of songs of the config file, or nil.
+// Songs are found by name, as the list of the game could be different from the one in the file.
+func songNode(songs *yaml.Node, name string) *yaml.Node {
+	for _, node := range songs.Content {
+		if node.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "name" && node.Content[i+1].Value == name {
+				return node
+			}
+		}
+	}
+	return nil
+}
+
+// Config file as YAML tree, and its list of songs, created if missing
+func loadSongsTree() (*yaml.Node, *yaml.Node, error) {
+	data, err := os.ReadFile(ConfigFileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s is not a mapping", ConfigFileName)
+	}
+	root := doc.Content[0]
+	var songs *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "songs" {
+			songs = root.Content[i+1]
+		}
+	}
+	if songs == nil {
+		songs = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "songs"}, songs)
+	}
+	if songs.Kind != yaml.SequenceNode { // "songs:" without any
+		*songs = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+	return &doc, songs, nil
+}
+
+func saveTree(doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return os.WriteFile(ConfigFileName, buf.Bytes(), 0644)
 }
 
 var noteRe = regexp.MustCompile(`([a-z']+)(\d+)?(.?)`)
 
-func noteFromMatch(parts []string, defaultDuration, fullDuration float64) (notes.SongNote, error) {
-	p := notes.PitchByName[parts[1]]
-	note := notes.SongNote{}
-	if p.Name == "" {
-		return note, fmt.Errorf("Unknown note: %#v", parts[1])
+// Note as it is written, its pitch is found later, after transposition.
+// Previous note is passed to take its duration, when duration is not written
+func noteFromMatch(parts []string, previous notes.SongNote, fullDuration float64) (notes.SongNote, error) {
+	note := notes.SongNote{Pitch: notes.Pause}
+	if parts[1] != notes.Pause.Name {
+		var err error
+		if note.Spelling, err = notes.ParseSpelling(parts[1]); err != nil {
+			return note, err
+		}
 	}
-	duration := defaultDuration
+	value, dotted := previous.Value, previous.Dotted
 	if parts[2] != "" {
 		nd, err := strconv.Atoi(parts[2])
 		if err != nil {
 			return note, fmt.Errorf("Failed to parse duration %s: %w", parts[2], err)
 		}
-		duration = fullDuration / float64(nd)
+		value, dotted = nd, false
 	}
 	if parts[3] == "." {
+		dotted = true
+	}
+	duration := fullDuration / float64(value)
+	if dotted {
 		duration *= 1.5
 	}
-	return notes.SongNote{
-		Pitch:    p,
-		Duration: duration,
-	}, nil
+	note.Duration = duration
+	note.Value = value
+	note.Dotted = dotted
+	return note, nil
 }
 
 // bpm - beats per minute
 // beat is a note in denominator of the time signature. Ex: in 4/4, 3/4 - beat is quarter note
 // So duration of full note for /4 tempo is 60 / bpm * 4 = 240 / bpm. Ex, for 60 bpm - 4 seconds. 120 bpm - 2 seconds.
 func (s Song) ParseNotes(fullDuration float64) (song []notes.SongNote, err error) {
-	matches := noteRe.FindAllStringSubmatch(s.Notes, -1)
+	return s.parseNotes(s.Notes, fullDuration)
+}
+
+// Notes of each voice of accompaniment, in the same time as ParseNotes
+func (s Song) ParseAccompaniment(fullDuration float64) (voices [][]notes.SongNote, err error) {
+	for _, text := range s.Accompaniment {
+		voice, err := s.parseNotes(text, fullDuration)
+		if err != nil {
+			return nil, err
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+// Parse notes, transpose them and check that flute could play them
+func (s Song) parseNotes(text string, fullDuration float64) ([]notes.SongNote, error) {
+	song, err := parseWritten(text, fullDuration)
+	if err != nil {
+		return nil, err
+	}
+	fifths := 0
+	if s.Transpose != 0 {
+		if fifths, err = s.Fifths(); err != nil {
+			return nil, err
+		}
+	}
+	if unplayable := transpose(song, s.Transpose, fifths); len(unplayable) > 0 {
+		return nil, fmt.Errorf("Notes %s are out of range, it should be from c to c''", strings.Join(unplayable, ", "))
+	}
+	return song, nil
+}
+
+func parseWritten(text string, fullDuration float64) (song []notes.SongNote, err error) {
+	matches := noteRe.FindAllStringSubmatch(text, -1)
 	time := TimeBeforeFirstNote // give some initial time to prepare for first note
-	defaultDuration := fullDuration / 4
+	previous := notes.SongNote{Value: 4}
 	for _, match := range matches {
-		n, err := noteFromMatch(match, defaultDuration, fullDuration)
+		n, err := noteFromMatch(match, previous, fullDuration)
 		if err != nil {
 			return nil, err
 		}
 		n.Time = time
-		time += n.Duration + BreathInterval*fullDuration
-		defaultDuration = n.Duration
+		time += n.Duration
+		previous = n
+		// Next note starts in time, but this one ends a bit earlier, to leave time to breathe
+		n.Duration -= math.Min(n.Duration*MaxBreathFraction, BreathInterval*fullDuration)
 		song = append(song, n)
 	}
 	return song, nil
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTiming(t *testing.T) {
+	song, err := Song{Name: "Test", Notes: "c4 d8 e2 f16"}.ParseNotes(2) // whole note is 2 seconds
+	assert.NoError(t, err)
+	starts := []float64{0, 0.5, 0.75, 1.75}           // on the beat, without gaps
+	durations := []float64{0.4, 0.1875, 0.9, 0.09375} // shorter by breath, 0.1 s, but at most a quarter of the note
+	for i, n := range song {
+		assert.InDelta(t, TimeBeforeFirstNote+starts[i], n.Time, 1e-9, "start of %d", i)
+		assert.InDelta(t, durations[i], n.Duration, 1e-9, "duration of %d", i)
+	}
+}
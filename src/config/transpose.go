@@ -0,0 +1,119 @@
+package config
+
+import (
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Farthest transposition, in semitones up or down
+const MaxTranspose = 12
+
+// Fifths of the key, after transposition
+func (s Song) Fifths() (int, error) {
+	fifths, err := notes.KeyFifths(s.Key)
+	if err != nil {
+		return 0, err
+	}
+	return transposeFifths(fifths, s.Transpose), nil
+}
+
+// Every semitone up adds 7 fifths. Of the two enharmonic keys, the one with less accidentals is taken,
+// and F sharp major is preferred to G flat major.
+func transposeFifths(fifths, semitones int) int {
+	f := ((fifths+7*semitones)%12 + 12) % 12
+	if f > 6 {
+		f -= 12
+	}
+	return f
+}
+
+// Sets pitches of the notes, shifted by semitones and spelled in the key with given fifths.
+// Returns names of the notes the flute could not play, each once.
+func transpose(song []notes.SongNote, semitones, fifths int) (unplayable []string) {
+	seen := make(map[string]bool)
+	for i := range song {
+		if song[i].Spelling.Letter == 0 { // pause
+			continue
+		}
+		song[i].Spelling = song[i].Spelling.Transpose(semitones, fifths)
+		p, ok := song[i].Spelling.Pitch()
+		if !ok {
+			name := song[i].Spelling.Name()
+			if !seen[name] {
+				unplayable = append(unplayable, name)
+				seen[name] = true
+			}
+			continue
+		}
+		song[i].Pitch = p
+	}
+	return unplayable
+}
+
+// Written notes of the song and all the voices of accompaniment
+func (s Song) written() ([]notes.SongNote, error) {
+	song, err := parseWritten(s.Notes, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, text := range s.Accompaniment {
+		voice, err := parseWritten(text, 1)
+		if err != nil {
+			return nil, err
+		}
+		song = append(song, voice...)
+	}
+	return song, nil
+}
+
+// Unplayable returns names of the notes that are out of the range of the flute, when song is transposed by semitones
+func (s Song) Unplayable(semitones int) ([]string, error) {
+	song, err := s.written()
+	if err != nil {
+		return nil, err
+	}
+	s.Transpose = semitones
+	fifths, err := s.Fifths()
+	if err != nil {
+		return nil, err
+	}
+	return transpose(song, semitones, fifths), nil
+}
+
+// SuggestTranspose finds transposition that fits the range of the flute, with the least accidentals to play.
+// Of equally good ones, the closest to the written key is taken. False if none fits.
+func (s Song) SuggestTranspose() (int, bool) {
+	written, err := s.written()
+	if err != nil {
+		return 0, false
+	}
+	best, bestHalf := 0, -1
+	for semitones := -MaxTranspose; semitones <= MaxTranspose; semitones++ {
+		song := append([]notes.SongNote(nil), written...)
+		t := s
+		t.Transpose = semitones
+		fifths, err := t.Fifths()
+		if err != nil {
+			return 0, false
+		}
+		if len(transpose(song, semitones, fifths)) > 0 {
+			continue
+		}
+		half := 0
+		for _, n := range song {
+			if n.Pitch.IsHalf {
+				half++
+			}
+		}
+		if bestHalf < 0 || half < bestHalf || half == bestHalf && abs(semitones) < abs(best) {
+			best, bestHalf = semitones, half
+		}
+	}
+	return best, bestHalf >= 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransposeFifths(t *testing.T) {
+	assert.Equal(t, 2, transposeFifths(0, 2))   // C to D
+	assert.Equal(t, -1, transposeFifths(0, 5))  // C to F
+	assert.Equal(t, 6, transposeFifths(0, 6))   // C to F sharp
+	assert.Equal(t, -3, transposeFifths(1, -4)) // G to E flat
+	assert.Equal(t, 0, transposeFifths(-2, 2))  // B flat to C
+}
+
+func TestParseTransposed(t *testing.T) {
+	song := Song{Name: "Test", Notes: "a b cis' p d'", Key: "a", Transpose: -2}
+	parsed, err := song.ParseNotes(1)
+	assert.NoError(t, err)
+	var names []string
+	for _, n := range parsed {
+		names = append(names, n.Spelling.Name())
+	}
+	assert.Equal(t, []string{"g", "a", "b", "", "c'"}, names)
+	assert.Equal(t, "c'", parsed[4].Pitch.Name)
+	fifths, err := song.Fifths()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fifths)
+
+	song.Transpose = -12
+	_, err = song.ParseNotes(1)
+	assert.Error(t, err)
+	unplayable, err := song.Unplayable(-12)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a,", "b,"}, unplayable)
+}
+
+func TestSuggestTranspose(t *testing.T) {
+	// d'' is out of range, fits a fourth down in C major
+	song := Song{Notes: "f' g' a' bes' c'' d''", Key: "f"}
+	semitones, ok := song.SuggestTranspose()
+	assert.True(t, ok)
+	assert.Equal(t, -5, semitones)
+
+	// already fits, and has no black keys to play
+	song = Song{Notes: "c d e f g"}
+	semitones, ok = song.SuggestTranspose()
+	assert.True(t, ok)
+	assert.Equal(t, 0, semitones)
+
+	// wider than the range of the flute
+	song = Song{Notes: "c c'' d''"}
+	_, ok = song.SuggestTranspose()
+	assert.False(t, ok)
+}
+
+func TestSetTranspose(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+	saved := Songs
+	defer func() { Songs = saved }()
+
+	data := "songs:\n  # the first one\n  - name: One\n    notes: c d e\n  - name: Two\n    notes: e d c\n"
+	assert.NoError(t, os.WriteFile(ConfigFileName, []byte(data), 0644))
+	Songs = []Song{{Name: "One", Notes: "c d e"}, {Name: "Two", Notes: "e d c"}}
+
+	assert.NoError(t, SetTranspose(1, 3))
+	assert.Equal(t, 3, Songs[1].Transpose)
+	written, _ := os.ReadFile(ConfigFileName)
+	assert.Equal(t, data+"    transpose: 3\n", string(written))
+
+	assert.NoError(t, SetTranspose(1, 0))
+	written, _ = os.ReadFile(ConfigFileName)
+	assert.Equal(t, data, string(written))
+
+	// songs are found by name, when the list is in different order than the file, or has songs that are not there
+	Songs = []Song{{Name: "Two", Notes: "e d c"}, {Name: "Three", Notes: "c"}}
+	assert.NoError(t, SetTranspose(0, 3))
+	written, _ = os.ReadFile(ConfigFileName)
+	assert.Equal(t, data+"    transpose: 3\n", string(written))
+	assert.Error(t, SetTranspose(1, 3))
+	assert.Error(t, SetTranspose(2, 3))
+}
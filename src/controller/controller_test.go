@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/stretchr/testify/assert"
+)
+
+// Window with the keys held by the test
+type keysWindow struct {
+	*ui.Offscreen
+	pressed, previous map[ui.Key]bool
+}
+
+func (w *keysWindow) Pressed(k ui.Key) bool     { return w.pressed[k] }
+func (w *keysWindow) JustPressed(k ui.Key) bool { return w.pressed[k] && !w.previous[k] }
+
+func (w *keysWindow) hold(keys ...byte) {
+	w.previous = w.pressed
+	w.pressed = make(map[ui.Key]bool)
+	for _, k := range keys {
+		w.pressed[ui.Key(k)] = true
+	}
+}
+
+func TestKeyboard(t *testing.T) {
+	k, err := NewKeyboard(nil)
+	assert.NoError(t, err)
+	win := &keysWindow{Offscreen: ui.NewOffscreen(pixel.R(0, 0, 10, 10))}
+
+	win.hold()
+	assert.Equal(t, -1.0, k.Pitch(win))
+	win.hold('Z')
+	assert.Equal(t, notes.C.Frequency, k.Pitch(win))
+	win.hold('Z', 'I')
+	assert.Equal(t, notes.FluteRange[len(notes.FluteRange)-1].Frequency, k.Pitch(win), "last pressed sounds")
+	win.hold('Z', 'I', ',')
+	c1, _ := notes.ParseSpelling("c'")
+	p, _ := c1.Pitch()
+	assert.Equal(t, p.Frequency, k.Pitch(win))
+	win.hold('Z', 'I')
+	assert.Equal(t, notes.FluteRange[len(notes.FluteRange)-1].Frequency, k.Pitch(win), "previous one sounds again when released")
+	win.hold('Z')
+	assert.Equal(t, notes.C.Frequency, k.Pitch(win))
+
+	k, err = NewKeyboard(map[string]string{"a": "d"})
+	assert.NoError(t, err)
+	win.hold('A')
+	assert.Equal(t, notes.FluteRange[3].Frequency, k.Pitch(win))
+
+	for _, mapping := range []map[string]string{{"ab": "c"}, {"A": "c"}, {"a": "h"}, {"a": "c'''"}} {
+		_, err = NewKeyboard(mapping)
+		assert.Error(t, err, "%v", mapping)
+	}
+}
+
+func TestMIDI(t *testing.T) {
+	// pipe is the virtual port, controller writes to one end, and the game reads from another
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	m := NewMIDI(r)
+	defer m.Close()
+
+	pitch := func(bytes []byte, expected float64) {
+		w.Write(bytes)
+		assert.Eventually(t, func() bool { return math.Abs(m.Pitch()-expected) < 0.01 }, time.Second, time.Millisecond, "after % x", bytes)
+	}
+	assert.Equal(t, -1.0, m.Pitch())
+	pitch([]byte{0x90, 72, 100}, notes.C.Frequency)       // note on
+	pitch([]byte{74, 100}, notes.FluteRange[3].Frequency) // running status
+	// clock, sysex and program change change nothing, and then the next note is played
+	pitch([]byte{0xF8, 0xF0, 1, 2, 3, 0xF7, 0xC0, 5, 0x90, 76, 100}, notes.FluteRange[5].Frequency)
+	pitch([]byte{0x90, 76, 0}, notes.FluteRange[3].Frequency) // zero velocity
+	pitch([]byte{0x90, 74, 0}, notes.C.Frequency)
+	pitch([]byte{0x80, 72, 64}, -1) // note off
+	pitch([]byte{0x91, 76, 90}, notes.FluteRange[5].Frequency)
+	pitch([]byte{0xB1, 123, 0}, -1) // all notes off
+	assert.NoError(t, m.Err())
+
+	w.Close()
+	assert.Eventually(t, func() bool { return m.Err() != nil }, time.Second, time.Millisecond)
+}
+
+func TestOpenMIDI(t *testing.T) {
+	_, err := OpenMIDI(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	// pipe again, but opened by path, like the device
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	device := fmt.Sprintf("/dev/fd/%d", r.Fd())
+	if _, err := os.Stat(device); err != nil {
+		t.Skip("pipe can't be opened by path here")
+	}
+	m, err := OpenMIDI(device)
+	assert.NoError(t, err)
+	defer m.Close()
+	w.Write([]byte{0x90, 72, 100})
+	assert.Eventually(t, func() bool { return math.Abs(m.Pitch()-notes.C.Frequency) < 0.01 }, time.Second, time.Millisecond)
+	w.Close()
+	assert.Eventually(t, func() bool { return m.Err() != nil }, time.Second, time.Millisecond)
+}
+
+func TestNoteFrequency(t *testing.T) {
+	assert.Equal(t, 440.0, NoteFrequency(69))
+	for i, p := range notes.FluteRange[1:] {
+		assert.InDelta(t, p.Frequency, NoteFrequency(72+i), 0.02, p.Name) // table is rounded
+	}
+}
@@ -0,0 +1,71 @@
+// Package controller lets to play the game without the flute: with the computer keyboard, or with MIDI controller
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+)
+
+// DefaultKeys are laid out like a piano, in two rows like in music trackers.
+// Lower octave is on the bottom row, with sharps on the row above it, and the upper one is on the top row, with sharps on the digits.
+var DefaultKeys = map[string]string{
+	"z": "c", "s": "cis", "x": "d", "d": "dis", "c": "e", "v": "f", "g": "fis",
+	"b": "g", "h": "gis", "n": "a", "j": "bes", "m": "b", ",": "c'",
+	"q": "c'", "2": "cis'", "w": "d'", "3": "dis'", "e": "e'", "r": "f'", "5": "fis'",
+	"t": "g'", "6": "gis'", "y": "a'", "7": "bes'", "u": "b'", "i": "c''",
+}
+
+// Characters of the keys that could be used. Their GLFW codes are the same as the codes of the characters.
+const keyChars = "',-./0123456789;=abcdefghijklmnopqrstuvwxyz[\\]`"
+
+// Keyboard plays notes with the keys of the computer keyboard. Like on the synthesizer, the last key pressed sounds.
+type Keyboard struct {
+	pitches map[ui.Key]notes.Pitch
+	held    []ui.Key // in the order they were pressed
+}
+
+// NewKeyboard with mapping of key characters to names of the notes, DefaultKeys when it is empty
+func NewKeyboard(mapping map[string]string) (*Keyboard, error) {
+	if len(mapping) == 0 {
+		mapping = DefaultKeys
+	}
+	k := &Keyboard{pitches: make(map[ui.Key]notes.Pitch)}
+	for char, name := range mapping {
+		if len(char) != 1 || !strings.Contains(keyChars, char) {
+			return nil, fmt.Errorf("Key %#v could not be used to play, use one of %s", char, keyChars)
+		}
+		sp, err := notes.ParseSpelling(name)
+		if err != nil {
+			return nil, err
+		}
+		p, ok := sp.Pitch()
+		if !ok {
+			return nil, fmt.Errorf("Note %#v of the key %#v is out of range, it should be from c to c''", name, char)
+		}
+		k.pitches[ui.Key(strings.ToUpper(char)[0])] = p
+	}
+	return k, nil
+}
+
+// Pitch of the key that is played, or -1 when no keys are pressed
+func (k *Keyboard) Pitch(win ui.Window) float64 {
+	held := k.held[:0]
+	for _, key := range k.held {
+		if win.Pressed(key) {
+			held = append(held, key)
+		}
+	}
+	k.held = held
+	for key := range k.pitches {
+		if win.JustPressed(key) {
+			k.held = append(k.held, key)
+		}
+	}
+	if len(k.held) == 0 {
+		return -1
+	}
+	return k.pitches[k.held[len(k.held)-1]].Frequency
+}
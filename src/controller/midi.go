@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MIDIDevices are raw MIDI ports of the system, like the ones of USB keyboards and wind controllers.
+// Virtual ones, for example from `modprobe snd-virmidi`, are there too.
+func MIDIDevices() []string {
+	var devices []string
+	for _, pattern := range []string{"/dev/snd/midiC*D*", "/dev/midi*"} {
+		found, _ := filepath.Glob(pattern)
+		devices = append(devices, found...)
+	}
+	return devices
+}
+
+// MIDI plays notes received from MIDI controller. When several notes are held, the last one sounds.
+type MIDI struct {
+	source io.ReadCloser
+
+	mu   sync.Mutex
+	held []int // numbers of the notes, in the order they were played
+	err  error // of reading, after which notes are not received anymore
+}
+
+// OpenMIDI starts listening to the device, or to the first one of MIDIDevices when it is empty
+func OpenMIDI(device string) (*MIDI, error) {
+	if device == "" {
+		devices := MIDIDevices()
+		if len(devices) == 0 {
+			return nil, errors.New("No MIDI devices found, connect one or set midi_device in the profile")
+		}
+		device = devices[0]
+	}
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("listening to MIDI", "device", device)
+	return NewMIDI(f), nil
+}
+
+// NewMIDI starts reading MIDI messages from the source, until it is closed
+func NewMIDI(source io.ReadCloser) *MIDI {
+	m := &MIDI{source: source}
+	go m.listen()
+	return m
+}
+
+func (m *MIDI) listen() {
+	r := bufio.NewReader(m.source)
+	var p parser
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			m.mu.Lock()
+			m.err = err
+			m.held = nil
+			m.mu.Unlock()
+			if !errors.Is(err, os.ErrClosed) {
+				slog.Warn("stopped reading MIDI", "error", err)
+			}
+			return
+		}
+		if msg := p.feed(b); msg != nil {
+			m.handle(msg)
+		}
+	}
+}
+
+func (m *MIDI) handle(msg []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case msg[0]&0xF0 == 0x90 && msg[2] > 0: // note on
+		m.release(int(msg[1]))
+		m.held = append(m.held, int(msg[1]))
+	case msg[0]&0xF0 == 0x80 || msg[0]&0xF0 == 0x90: // note off, or note on with zero velocity
+		m.release(int(msg[1]))
+	case msg[0]&0xF0 == 0xB0 && (msg[1] == 120 || msg[1] == 123): // all sound off, all notes off
+		m.held = m.held[:0]
+	}
+}
+
+func (m *MIDI) release(note int) {
+	held := m.held[:0]
+	for _, n := range m.held {
+		if n != note {
+			held = append(held, n)
+		}
+	}
+	m.held = held
+}
+
+// Pitch of the note that is played, or -1 when none are
+func (m *MIDI) Pitch() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.held) == 0 {
+		return -1
+	}
+	return NoteFrequency(m.held[len(m.held)-1])
+}
+
+// Err is the error that stopped reading, nil while it goes on, or io.EOF when device is gone
+func (m *MIDI) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Close stops listening
+func (m *MIDI) Close() error {
+	return m.source.Close()
+}
+
+// NoteFrequency of the MIDI note number, in equal temperament where a' (69) is 440 Hz.
+// Recorder sounds octave higher than written, so c of it is 72.
+func NoteFrequency(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+// parser assembles bytes of the MIDI stream into channel messages
+type parser struct {
+	status byte // of the message being read, kept for the running status
+	data   []byte
+	sysex  bool
+}
+
+// feed the next byte, to get the message when it is complete
+func (p *parser) feed(b byte) []byte {
+	switch {
+	case b >= 0xF8: // realtime messages could come at any moment, and don't change anything
+		return nil
+	case b == 0xF0:
+		p.sysex, p.status = true, 0
+		return nil
+	case b == 0xF7:
+		p.sysex = false
+		return nil
+	case b >= 0xF0: // system common messages cancel the running status, their data is ignored
+		p.sysex, p.status = false, 0
+		return nil
+	case b >= 0x80:
+		p.sysex, p.status, p.data = false, b, p.data[:0]
+		return nil
+	}
+	if p.sysex || p.status == 0 {
+		return nil
+	}
+	p.data = append(p.data, b)
+	length := 2
+	if kind := p.status & 0xF0; kind == 0xC0 || kind == 0xD0 { // program change and channel pressure
+		length = 1
+	}
+	if len(p.data) < length {
+		return nil
+	}
+	msg := append([]byte{p.status}, p.data...)
+	p.data = p.data[:0]
+	return msg
+}
@@ -1,49 +1,95 @@
 package ear
 
 import (
-	"log"
+	"math"
+	"sync/atomic"
+	"time"
 
 	"github.com/MarkKremer/microphone"
 	"github.com/faiface/beep"
 
-	"github.com/bunyk/fasolasi/src/yin"
+	"github.com/bunyk/fasolasi/src/listen"
 )
 
 type Ear struct {
-	micStream     beep.Streamer
-	MicBuffer     [][2]float64
-	pitchDetector yin.Yin
-	Pitch         float64
+	micStream      beep.Streamer
+	MicBuffer      [][2]float64
+	listener       *listen.Listener
+	pitch          atomic.Uint64 // bits of float64, as it is written by the listening goroutine
+	probability    atomic.Uint64
+	latency        atomic.Int64
+	bufferTime     time.Duration // of sound that fits the buffer
+	holdUntil      atomic.Int64  // Unix time in nanoseconds until which pitch is not updated
+	calibrateUntil atomic.Int64  // Unix time in nanoseconds until which the player is silent
 }
 
 func (e *Ear) listen() {
 	go func() {
 		for {
 			e.micStream.Stream(e.MicBuffer)
-			e.Pitch = e.pitchDetector.GetPitch2(e.MicBuffer)
-			e.pitchDetector.Clean()
+			filled := time.Now()
+			e.listener.Calibrating = filled.UnixNano() < e.calibrateUntil.Load()
+			pitch := e.listener.Pitch(e.MicBuffer)
+			e.probability.Store(math.Float64bits(e.listener.Probability))
+			e.latency.Store(int64(e.bufferTime + time.Since(filled)))
+			if time.Now().UnixNano() >= e.holdUntil.Load() {
+				e.pitch.Store(math.Float64bits(pitch))
+			}
 		}
 	}()
 }
 
-func New(sampleRate, bufSize int) *Ear {
+// Pitch is the frequency last heard, or negative for silence
+func (e *Ear) Pitch() float64 {
+	return math.Float64frombits(e.pitch.Load())
+}
+
+// Probability is certainty of the last pitch detected, from 0 to 1
+func (e *Ear) Probability() float64 {
+	return math.Float64frombits(e.probability.Load())
+}
+
+// Latency is the time from the start of the sound in the buffer, to the pitch of it being detected
+func (e *Ear) Latency() time.Duration {
+	return time.Duration(e.latency.Load())
+}
+
+// HoldPitch keeps Pitch unchanged for the given time.
+// Used to not hear sounds of the game itself, like metronome clicks.
+func (e *Ear) HoldPitch(d time.Duration) {
+	e.holdUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+// Calibrate for the given time, while the game plays and the player is silent,
+// to learn how loud the game is heard, and not take it for the player later.
+func (e *Ear) Calibrate(d time.Duration) {
+	e.calibrateUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+// New opens the default microphone, and starts listening to it.
+// Sound played by the game is the reference, to not take it for the player, when heard in the speakers.
+func New(sampleRate, bufSize int, reference listen.Reference, latency time.Duration) (*Ear, error) {
 	if err := microphone.Init(); err != nil { // without this you will get "PortAudio not initialized" error later
-		log.Fatal(err)
+		return nil, err
 	}
 
 	// Create microphone stream
 	micStream, _, err := microphone.OpenDefaultStream(beep.SampleRate(sampleRate), 1)
 	if err != nil {
-		log.Fatal(err)
+		microphone.Terminate()
+		return nil, err
 	}
 	var e = &Ear{
-		MicBuffer:     make([][2]float64, bufSize),
-		micStream:     micStream,
-		pitchDetector: yin.NewYin(float64(sampleRate), bufSize, 0.05),
+		MicBuffer:  make([][2]float64, bufSize),
+		micStream:  micStream,
+		listener:   listen.NewListener(sampleRate, bufSize, reference, latency.Seconds()),
+		bufferTime: time.Duration(bufSize) * time.Second / time.Duration(sampleRate),
 	}
-	if ear := micStream.Start(); ear != nil { // Start recording
-		log.Fatal(err)
+	if err := micStream.Start(); err != nil { // Start recording
+		micStream.Close()
+		microphone.Terminate() // so the next attempt starts from scratch
+		return nil, err
 	}
 	e.listen()
-	return e
+	return e, nil
 }
@@ -0,0 +1,62 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+// Debug overlay is toggled with F3, and stays on for the next sessions
+var showDebug bool
+
+// How fast average frame time follows the changes, from 0 to 1
+const frameTimeSmoothing = 0.05
+
+// Lines of the overlay with the state of input and scoring
+func (s *Session) debugLines(frame, pitch float64) []string {
+	fps := 0.0
+	if s.frameTime > 0 {
+		fps = 1 / s.frameTime
+	}
+	lines := []string{
+		fmt.Sprintf("FPS: %.0f, frame: %.1f ms (last %.1f ms)", fps, s.frameTime*1000, frame*1000),
+	}
+	heard := fmt.Sprintf("Heard: %.1f Hz", pitch)
+	if s.ear != nil {
+		heard += fmt.Sprintf(", probability %.2f, latency %d ms", s.ear.Probability(), s.ear.Latency().Milliseconds())
+	}
+	lines = append(lines, heard)
+	if p, _ := notes.GuessNote(pitch); p.Frequency > 0 {
+		lines = append(lines, fmt.Sprintf("Note: %s %+.0f cents", p.Name, p.Cents(pitch)))
+	} else {
+		lines = append(lines, "Note: none")
+	}
+	lines = append(lines,
+		fmt.Sprintf("Playing: %s, time %.2f of %.2f s", s.Playing.Name, s.Duration, s.SongDuration),
+		fmt.Sprintf("Cursor: %d of %d notes, round %d", s.SongCursor, len(s.Song), s.Round),
+		fmt.Sprintf("Score: %.1f, %s, combo %d x%d", s.Score, s.LastJudgement, s.Scorer.Combo, s.Scorer.Multiplier()),
+	)
+	if s.Follow != nil {
+		lines = append(lines, fmt.Sprintf("Follow rate: %.2f", s.Follow.Rate))
+	}
+	return lines
+}
+
+// Small text in the top right corner, over a light background
+func renderDebug(win ui.Window, lines []string) {
+	const scale = 0.5
+	txt := text.New(pixel.ZV, ui.TextAtlas)
+	txt.Color = colornames.Black
+	for _, line := range lines {
+		fmt.Fprintln(txt, line)
+	}
+	b := txt.Bounds()
+	size := pixel.V(b.W(), b.H()).Scaled(scale)
+	corner := win.Bounds().Max.Sub(pixel.V(10, 10))
+	box := pixel.R(corner.X-size.X-10, corner.Y-size.Y-10, corner.X, corner.Y)
+	renderTextBox(win, box, txt, scale, 0.8, 5)
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/ui"
+	"golang.org/x/image/colornames"
+)
+
+// Longest line of the error message, in characters, and most lines shown. The rest is in the log.
+const (
+	errorLineLength = 50
+	errorLines      = 5
+)
+
+// ErrorScene tells the player what went wrong, and returns to the menu
+type ErrorScene struct {
+	Title string // what failed, like "Failed to start the game"
+	Err   error
+	Next  ui.Scene // scene to return to
+}
+
+// NewErrorScene writes the error to the log, and shows it. Without next scene it returns to the main menu.
+func NewErrorScene(title string, err error, next ui.Scene) *ErrorScene {
+	slog.Error(title, "err", err)
+	if next == nil {
+		next = &MainMenu{}
+	}
+	return &ErrorScene{Title: title, Err: err, Next: next}
+}
+
+func (es *ErrorScene) Loop(win ui.Window) ui.Scene {
+	win.Clear(config.BackgroundColor)
+	ui.Prepare()
+	defer ui.Finish(win)
+
+	message := wrapText(es.Err.Error(), errorLineLength)
+	if len(message) > errorLines {
+		message = message[:errorLines]
+		message[errorLines-1] += " ..."
+	}
+	fl := ui.FlexRows(win.Bounds(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, len(message)+3)
+	ui.Label(win, fl(0), es.Title, colornames.Darkred)
+	for i, line := range message {
+		ui.Label(win, fl(i+1), line, colornames.Black)
+	}
+	ui.Label(win, fl(len(message)+1), "Details are in "+config.LogFileName, colornames.Dimgray)
+	// Escape is not used, it is still pressed when the menu opens, and the menu would be closed by it
+	if ui.Button(win, fl(len(message)+2), "OK") || win.JustPressed(ui.KeyEnter) {
+		return es.Next
+	}
+	return es
+}
+
+// Split text into lines by words, to fit the screen
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
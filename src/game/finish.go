@@ -4,35 +4,61 @@ import (
 	"fmt"
 
 	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/gameplay"
 	"github.com/bunyk/fasolasi/src/ui"
-	"github.com/faiface/pixel/pixelgl"
 	"golang.org/x/image/colornames"
 )
 
 type FinishScene struct {
-	SongID int
-	Mode   string
-	BPM    int
-	Score  int
+	SongID     int
+	Mode       string
+	BPM        int
+	Difficulty config.Difficulty
+	gameplay.Result
+	Replay *Replay
 }
 
-func (fs *FinishScene) Loop(win *pixelgl.Window) ui.Scene {
+func (fs *FinishScene) Loop(win ui.Window) ui.Scene {
 	win.Clear(config.BackgroundColor)
 	renderFingering(win)
 	ui.Prepare()
 	defer ui.Finish(win)
 
-	fl := ui.FlexRows(win.Bounds(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, 4)
+	rows := 5
+	if fs.Grade != "" || fs.RecommendedBPM > 0 || fs.Mode == "practice" {
+		rows++
+	}
+	fl := ui.FlexRows(win.Bounds(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, rows)
 
 	ui.Label(win, fl(0), fmt.Sprintf("Score: %d", fs.Score), colornames.Black)
+	row := 1
+	if fs.Grade != "" {
+		ui.Label(win, fl(row), fmt.Sprintf("Grade: %s, max combo: %d", fs.Grade, fs.MaxCombo), colornames.Black)
+		row++
+	}
+	if fs.Mode == "practice" {
+		ui.Label(win, fl(row), fmt.Sprintf("Clean passes: %d, reached %d bpm", fs.Passes, fs.BPM), colornames.Black)
+		row++
+	}
+	if fs.RecommendedBPM > 0 {
+		ui.Label(win, fl(row), fmt.Sprintf("Ready for challenge at %d bpm", fs.RecommendedBPM), colornames.Black)
+		row++
+	}
 
-	if ui.Button(win, fl(1), "Retry") {
-		return NewSession(fs.SongID, fs.Mode, fs.BPM)
+	// replay could have the song that is not in the list anymore
+	if fs.SongID >= 0 && ui.Button(win, fl(row), "Retry") {
+		if fs.Mode == "practice" {
+			return NewSectionMenu(fs.SongID)
+		}
+		return NewSession(fs.SongID, fs.Mode, fs.BPM, fs.Difficulty)
+	}
+	if ui.Button(win, fl(row+1), "Watch replay") && fs.Replay != nil {
+		return NewReplay(fs.Replay)
 	}
-	if ui.Button(win, fl(2), "Select another song") {
+	if ui.Button(win, fl(row+2), "Select another song") {
 		return NewSongMenu()
 	}
-	if ui.Button(win, fl(3), "Main menu") {
+	if ui.Button(win, fl(row+3), "Main menu") || win.JustPressed(ui.KeyEscape) {
 		return &MainMenu{}
 	}
 	return fs
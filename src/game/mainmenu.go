@@ -1,17 +1,16 @@
 package game
 
 import (
-	"fmt"
+	"log/slog"
 
 	"github.com/bunyk/fasolasi/src/config"
 	"github.com/bunyk/fasolasi/src/ui"
-	"github.com/faiface/pixel/pixelgl"
 )
 
 type MainMenu struct {
 }
 
-func (mm *MainMenu) Loop(win *pixelgl.Window) ui.Scene {
+func (mm *MainMenu) Loop(win ui.Window) ui.Scene {
 	win.Clear(config.BackgroundColor)
 	renderFingering(win)
 
@@ -20,16 +19,22 @@ func (mm *MainMenu) Loop(win *pixelgl.Window) ui.Scene {
 
 	choice := ui.Menu(win, win.Bounds(), []string{
 		"Play",
+		"Record a song",
+		"Settings",
 		"Exit",
 	})
-	if win.Pressed(pixelgl.KeyEscape) {
-		choice = 1
+	if win.JustPressed(ui.KeyEscape) {
+		choice = 3
 	}
 	switch choice {
 	case 0:
 		return NewSongMenu()
 	case 1:
-		fmt.Println("Bye")
+		return NewRecord()
+	case 2:
+		return &SettingsMenu{}
+	case 3:
+		slog.Info("Bye")
 		win.SetClosed(true)
 	}
 
@@ -1,42 +1,198 @@
 package game
 
 import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/audio"
 	"github.com/bunyk/fasolasi/src/config"
 	"github.com/bunyk/fasolasi/src/ui"
-	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel"
 )
 
 type ModeMenu struct {
-	SongID int
+	SongID     int
+	BPM        int
+	Difficulty int // index in config.Difficulties
+	preview    *audio.Synth
+	unplayable []string // notes out of range of the flute, in the selected transposition
+}
+
+func NewModeMenu(songID int) *ModeMenu {
+	mm := &ModeMenu{
+		SongID:     songID,
+		BPM:        config.CurrentProfile.Tempo(config.Songs[songID]),
+		Difficulty: config.CurrentProfile.DifficultyIndex(),
+	}
+	mm.checkRange()
+	return mm
 }
 
-func (mm *ModeMenu) Loop(win *pixelgl.Window) ui.Scene {
+func (mm *ModeMenu) Loop(win ui.Window) ui.Scene {
 	win.Clear(config.BackgroundColor)
 	renderFingering(win)
 	ui.Prepare()
 	defer ui.Finish(win)
+	if mm.preview != nil && !mm.preview.Finished() {
+		ui.IgnoreVoice() // microphone hears the song
+	}
+
+	song := config.Songs[mm.SongID]
+	fl := ui.FlexRows(win.Bounds(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing/2, 10)
+
+	mm.setBPM(mm.BPM + config.TempoStep*ui.Spinner(win, fl(0), fmt.Sprintf("%d bpm", mm.BPM)))
+	percent := mm.BPM * 100 / song.TargetBPM()
+	if change := ui.Spinner(win, fl(1), fmt.Sprintf("%d%% of %d bpm", percent, song.TargetBPM())); change != 0 {
+		if change < 0 && percent%10 != 0 {
+			percent = percent / 10 * 10 // to the previous multiple of 10%
+		} else {
+			percent = (percent/10 + change) * 10 // to the next one
+		}
+		mm.setBPM(song.TargetBPM() * percent / 100)
+	}
+	difficulty := config.Difficulties[mm.Difficulty]
+	mm.Difficulty += ui.Spinner(win, fl(2), "Difficulty: "+difficulty.Name)
+	mm.Difficulty = (mm.Difficulty + len(config.Difficulties)) % len(config.Difficulties)
+
+	listen := "Listen first"
+	if mm.preview != nil && !mm.preview.Finished() {
+		listen = "Stop listening"
+	}
+	if ui.Button(win, fl(3), listen) && len(mm.unplayable) == 0 {
+		if err := mm.togglePreview(); err != nil {
+			return NewErrorScene("Failed to play the song", err, mm)
+		}
+	}
 
-	choice := ui.Menu(win, win.Bounds(), []string{
+	row := fl(4)
+	fit := pixel.R(row.Max.X-100, row.Min.Y, row.Max.X, row.Max.Y)
+	row.Max.X = fit.Min.X - 10 - 2*ui.VoiceMargin() // notes of "+" and "Fit" are between them
+	if change := ui.Spinner(win, row, transposeLabel(song.Transpose)); change != 0 {
+		mm.setTranspose(song.Transpose + change)
+	}
+	if ui.Button(win, fit, "Fit") {
+		if semitones, ok := song.SuggestTranspose(); ok {
+			mm.setTranspose(semitones)
+		}
+	}
+	if len(mm.unplayable) > 0 {
+		renderStatus(win, 0, outOfRange(mm.unplayable))
+	}
+
+	choice := -1
+	for i, label := range []string{
 		"Training",
-		"30 bpm challenge",
-		"60 bpm challenge",
-		"90 bpm challenge",
+		"Challenge",
+		"Follow me",
+		"Practice a section",
 		"← back to songs",
-	})
-	if win.Pressed(pixelgl.KeyEscape) {
+	} {
+		if ui.Button(win, fl(i+5), label) {
+			choice = i
+		}
+	}
+	if win.JustPressed(ui.KeyEscape) {
 		choice = 4
 	}
+	if choice >= 0 && choice < 4 && len(mm.unplayable) > 0 {
+		choice = -1 // could not be played, until transposed
+	}
+	if choice >= 0 && mm.preview != nil {
+		mm.preview.Stop()
+	}
+	if choice >= 0 && choice < 4 {
+		mm.remember()
+	}
 	switch choice {
 	case 0:
-		return NewSession(mm.SongID, "training", 60)
+		return NewSession(mm.SongID, "training", mm.BPM, difficulty)
 	case 1:
-		return NewSession(mm.SongID, "challenge", 30)
+		return NewSession(mm.SongID, "challenge", mm.BPM, difficulty)
 	case 2:
-		return NewSession(mm.SongID, "challenge", 60)
+		return NewSession(mm.SongID, "follow", mm.BPM, difficulty)
 	case 3:
-		return NewSession(mm.SongID, "challenge", 90)
+		return NewSectionMenu(mm.SongID)
 	case 4:
-		return &SongMenu{}
+		return NewSongMenu() // with the last search and tags
 	}
 	return mm
 }
+
+// Start or stop playing the song in the selected tempo
+func (mm *ModeMenu) togglePreview() error {
+	if mm.preview != nil && !mm.preview.Finished() {
+		mm.preview.Stop()
+		return nil
+	}
+	song := config.Songs[mm.SongID]
+	melody, err := song.ParseNotes(240.0 / float64(mm.BPM))
+	if err != nil {
+		return err
+	}
+	voices, err := songVoices(song, melody, true, config.CurrentProfile.Accompaniment, mm.BPM, 0)
+	if err != nil {
+		return err
+	}
+	mm.preview = audio.NewSynth(voices, nil)
+	audio.Play(mm.preview)
+	return nil
+}
+
+func (mm *ModeMenu) setBPM(bpm int) {
+	if bpm < config.MinBPM {
+		bpm = config.MinBPM
+	}
+	if bpm > config.MaxBPM {
+		bpm = config.MaxBPM
+	}
+	mm.BPM = bpm
+}
+
+// Save selected tempo and difficulty to the profile
+func (mm *ModeMenu) remember() {
+	config.CurrentProfile.SetTempo(config.Songs[mm.SongID], mm.BPM)
+	config.CurrentProfile.Difficulty = config.Difficulties[mm.Difficulty].Name
+	if err := config.CurrentProfile.Save(); err != nil {
+		slog.Error("Failed to save profile", "err", err)
+	}
+}
+
+// Transpose the song, and save it in the config file
+func (mm *ModeMenu) setTranspose(semitones int) {
+	if semitones < -config.MaxTranspose || semitones > config.MaxTranspose {
+		return
+	}
+	if mm.preview != nil {
+		mm.preview.Stop()
+	}
+	if err := config.SetTranspose(mm.SongID, semitones); err != nil {
+		slog.Error("Failed to save transposition", "song", config.Songs[mm.SongID].Name, "err", err)
+		config.Songs[mm.SongID].Transpose = semitones // at least until the game is closed
+	}
+	mm.checkRange()
+}
+
+func (mm *ModeMenu) checkRange() {
+	song := config.Songs[mm.SongID]
+	var err error
+	if mm.unplayable, err = song.Unplayable(song.Transpose); err != nil {
+		slog.Warn("Failed to check range", "song", song.Name, "err", err)
+	}
+}
+
+func transposeLabel(semitones int) string {
+	if semitones == 0 {
+		return "Not transposed"
+	}
+	return fmt.Sprintf("Transpose: %+d", semitones)
+}
+
+// Status line listing the first few notes out of range
+func outOfRange(unplayable []string) string {
+	const shown = 4
+	if len(unplayable) <= shown {
+		return "Out of range: " + strings.Join(unplayable, " ")
+	}
+	return fmt.Sprintf("Out of range: %s and %d more", strings.Join(unplayable[:shown], " "), len(unplayable)-shown)
+}
@@ -1,12 +1,12 @@
 package game
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 
 	"github.com/bunyk/fasolasi/src/ui"
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 )
 
 type ParticleSystem struct {
@@ -36,10 +36,10 @@ func (p Particle) CurrentLocation() pixel.Vec {
 	))
 }
 
-func NewParticleSystem(filename string, width, height float64) *ParticleSystem {
+func NewParticleSystem(filename string, width, height float64) (*ParticleSystem, error) {
 	spritesheet, err := ui.LoadPicture(filename)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("Failed to load sprites: %w", err)
 	}
 
 	var sprites []*pixel.Sprite
@@ -55,7 +55,7 @@ func NewParticleSystem(filename string, width, height float64) *ParticleSystem {
 	return &ParticleSystem{
 		Sprites: sprites,
 		Batch:   batch,
-	}
+	}, nil
 }
 
 func (ps *ParticleSystem) Spawn(src, dst pixel.Vec) {
@@ -72,7 +72,7 @@ func (ps *ParticleSystem) Spawn(src, dst pixel.Vec) {
 	})
 }
 
-func (ps *ParticleSystem) UpdateAndRender(win *pixelgl.Window, dt float64) {
+func (ps *ParticleSystem) UpdateAndRender(win ui.Window, dt float64) {
 	haveParticles := len(ps.Particles)
 	for i := 0; i < haveParticles; i++ {
 		ps.Particles[i].Time += dt
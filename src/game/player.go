@@ -0,0 +1,113 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/audio"
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/controller"
+	"github.com/bunyk/fasolasi/src/ear"
+	"github.com/bunyk/fasolasi/src/listen"
+	"github.com/bunyk/fasolasi/src/ui"
+)
+
+// Where the notes played come from: microphone or MIDI controller, depending on the profile, and keyboard over them
+type player struct {
+	ear  *ear.Ear // nil when other input is used
+	midi *controller.MIDI
+	keys *controller.Keyboard
+}
+
+func newPlayer() (*player, error) {
+	profile := config.CurrentProfile
+	keys, err := controller.NewKeyboard(profile.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong keys in the profile: %w", err)
+	}
+	p := &player{keys: keys}
+	switch profile.Input {
+	case config.InputMicrophone, "":
+		p.ear, err = sharedEar()
+	case config.InputMIDI:
+		p.midi, err = sharedMIDI(profile.MIDIDevice)
+	case config.InputKeyboard:
+	default:
+		err = fmt.Errorf("Unknown input %#v in the profile, it should be one of: %s", profile.Input, strings.Join(config.Inputs, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Pitch played in this frame, -1 for silence
+func (p *player) Pitch(win ui.Window) float64 {
+	if kp := p.keys.Pitch(win); kp > 0 {
+		return kp
+	}
+	return p.heard()
+}
+
+// Pitch played on the instrument, without the keyboard
+func (p *player) heard() float64 {
+	if p.midi != nil {
+		return p.midi.Pitch()
+	}
+	if p.ear != nil {
+		return p.ear.Pitch()
+	}
+	return -1
+}
+
+var theEar *ear.Ear
+
+// Microphone could hear what the game plays, so it is compared with it
+var played = listen.Reference{SampleRate: config.SpeakerSampleRate, Recent: audio.Recent}
+
+// Microphone is opened only once, and then all the scenes listen to it
+func sharedEar() (*ear.Ear, error) {
+	if theEar == nil {
+		var err error
+		if theEar, err = ear.New(config.MicrophoneSampleRate, config.MicrophoneBufferLength, played, config.SpeakerLatency*2); err != nil {
+			return nil, fmt.Errorf("Failed to open microphone: %w", err)
+		}
+	}
+	return theEar, nil
+}
+
+var (
+	theMIDI       *controller.MIDI
+	theMIDIDevice string
+)
+
+// MIDI device is also opened once, and again when it is changed in the profile, or was disconnected
+func sharedMIDI(device string) (*controller.MIDI, error) {
+	if theMIDI != nil && (theMIDI.Err() != nil || device != theMIDIDevice) {
+		theMIDI.Close()
+		theMIDI = nil
+	}
+	if theMIDI == nil {
+		m, err := controller.OpenMIDI(device)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open MIDI device: %w", err)
+		}
+		theMIDI, theMIDIDevice = m, device
+	}
+	return theMIDI, nil
+}
+
+// SetupVoice makes menus listen to the player, when voice control is turned on in the profile
+func SetupVoice() error {
+	if !config.CurrentProfile.VoiceControl || config.CurrentProfile.Input == config.InputKeyboard {
+		ui.SetVoice(nil)
+		return nil
+	}
+	p, err := newPlayer()
+	if err != nil {
+		ui.SetVoice(nil)
+		return err
+	}
+	ui.SetVoice(p.heard)
+	return nil
+}
@@ -0,0 +1,41 @@
+package game
+
+import (
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/gameplay"
+	"github.com/bunyk/fasolasi/src/ui"
+)
+
+func NewPracticeSession(songID, bpm, from, to int, countIn bool, difficulty config.Difficulty) ui.Scene {
+	s, err := setupSession(songID, Replay{
+		Song:       config.Songs[songID],
+		Mode:       "practice",
+		BPM:        bpm,
+		Difficulty: difficulty,
+		Metronome:  config.CurrentProfile.Metronome,
+		From:       from,
+		To:         to,
+		CountIn:    countIn,
+	}, nil)
+	if err != nil {
+		return NewErrorScene("Failed to start the practice", err, NewSectionMenu(songID))
+	}
+	return s
+}
+
+func practiceSession(songID int, r Replay, input Input) (*Session, error) {
+	song, err := r.Song.ParseNotes(1.0)
+	if err != nil {
+		return nil, err
+	}
+	p := gameplay.NewPractice(song, r.From, r.To, r.CountIn, r.Song.TargetBPM())
+	if r.BPM > p.TargetBPM {
+		r.BPM = p.TargetBPM
+	}
+	s, err := newSession(songID, r, input, p.Section(r.BPM))
+	if err != nil {
+		return nil, err
+	}
+	s.Practice = p
+	return s, nil
+}
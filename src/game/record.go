@@ -0,0 +1,231 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bunyk/fasolasi/src/audio"
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/gameplay"
+	"github.com/bunyk/fasolasi/src/highway"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/transcribe"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+// Shortest notes that could be written down
+var recordGrids = []int{4, 8, 16}
+
+const (
+	recordSetup = iota
+	recording
+	recorded
+)
+
+// Scene that listens to the player and writes down notes played, to create a new song
+type Record struct {
+	BPM       int
+	Grid      int // index in recordGrids
+	Name      string
+	state     int
+	player    *player
+	metronome *gameplay.Metronome
+	origin    float64 // time of the first beat after count-in, or -1 without metronome
+	Duration  float64 // of recording, in seconds
+	lastTime  time.Time
+	events    []transcribe.Event
+	played    []transcribe.Note
+	notation  string
+	err       error // of saving the song
+}
+
+func NewRecord() *Record {
+	return &Record{
+		BPM:  config.DefaultTempo,
+		Grid: 1,
+	}
+}
+
+func (r *Record) Loop(win ui.Window) ui.Scene {
+	switch r.state {
+	case recording:
+		return r.recordingLoop(win)
+	case recorded:
+		return r.recordedLoop(win)
+	}
+	win.Clear(config.BackgroundColor)
+	renderFingering(win)
+	ui.Prepare()
+	defer ui.Finish(win)
+
+	fl := ui.FlexRows(win.Bounds(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, 4)
+	r.BPM += config.TempoStep * ui.Spinner(win, fl(0), fmt.Sprintf("%d bpm", r.BPM))
+	if r.BPM < config.MinBPM {
+		r.BPM = config.MinBPM
+	}
+	if r.BPM > config.MaxBPM {
+		r.BPM = config.MaxBPM
+	}
+	r.Grid += ui.Spinner(win, fl(1), fmt.Sprintf("Shortest note: 1/%d", recordGrids[r.Grid]))
+	r.Grid = (r.Grid + len(recordGrids)) % len(recordGrids)
+	if ui.Button(win, fl(2), "Start recording") {
+		if err := r.start(); err != nil {
+			return NewErrorScene("Failed to start recording", err, r)
+		}
+	}
+	if ui.Button(win, fl(3), "← back") || win.JustPressed(ui.KeyEscape) {
+		return &MainMenu{}
+	}
+	return r
+}
+
+func (r *Record) start() error {
+	p, err := newPlayer()
+	if err != nil {
+		return err
+	}
+	r.state = recording
+	r.player = p
+	r.events = r.events[:0]
+	r.played = nil
+	r.Duration = 0
+	r.lastTime = time.Now()
+	r.metronome = nil
+	r.origin = -1
+	if config.CurrentProfile.Metronome {
+		r.origin = 240.0 / float64(r.BPM) // one bar of count-in
+		r.metronome = gameplay.NewMetronome(r.BPM, r.origin)
+	}
+	return nil
+}
+
+func (r *Record) recordingLoop(win ui.Window) ui.Scene {
+	dt := time.Since(r.lastTime).Seconds()
+	r.lastTime = time.Now()
+	r.Duration += dt
+
+	// keys are handled after the frame, so they are not typed into the name of the song
+	cancel := win.JustPressed(ui.KeyEscape)
+	stop := win.JustPressed(ui.KeySpace) || win.JustPressed(ui.KeyEnter)
+
+	if r.metronome != nil {
+		if click, accent := r.metronome.Tick(r.Duration); click {
+			audio.Play(audio.Click(accent))
+			if r.player.ear != nil {
+				r.player.ear.HoldPitch(config.ClickHoldTime)
+			}
+		}
+	}
+	pitch, _ := notes.GuessNote(r.player.Pitch(win))
+	r.events = append(r.events, transcribe.Event{Time: r.Duration, Pitch: pitch})
+	r.played = transcribe.Segment(r.events, transcribe.DefaultMinDuration)
+
+	played := make([]gameplay.PlayedNote, len(r.played))
+	for i, n := range r.played {
+		played[i] = gameplay.PlayedNote{
+			SongNote: notes.SongNote{Pitch: n.Pitch, Time: n.Start, Duration: n.End - n.Start},
+			Correct:  true,
+		}
+	}
+
+	win.Clear(config.BackgroundColor)
+	if r.player.ear != nil {
+		soundVisualization(win, colornames.Blue, r.player.ear.MicBuffer)
+	}
+	staff := highway.PianoRoll(win.Bounds())
+	highway.Highlight(win, staff, colornames.Salmon, pitch)
+	highway.Lines(win, staff)
+	highway.Notes(win, staff, nil, played, r.Duration)
+	renderStatus(win, 0, fmt.Sprintf("Recording at %d bpm, notes: %d", r.BPM, len(r.played)))
+	renderStatus(win, 1, "Space to stop, Escape to cancel")
+	if r.metronome != nil && r.Duration < r.origin {
+		renderMessage(win, fmt.Sprint(int(r.Duration*float64(r.BPM)/60)+1)) // count in
+	}
+	win.Update()
+
+	if cancel {
+		r.state = recordSetup
+	} else if stop {
+		r.stop()
+	}
+	return r
+}
+
+// Finish recording and write down the notes
+func (r *Record) stop() {
+	r.state = recorded
+	r.err = nil
+	origin := r.origin
+	if origin < 0 && len(r.played) > 0 {
+		origin = r.played[0].Start
+	}
+	r.notation = transcribe.Notation(transcribe.Quantize(r.played, origin, r.BPM, recordGrids[r.Grid]))
+	r.Name = uniqueSongName("Recorded " + time.Now().Format("2006-01-02 15:04"))
+}
+
+func (r *Record) recordedLoop(win ui.Window) ui.Scene {
+	win.Clear(config.BackgroundColor)
+	ui.Prepare()
+	defer ui.Finish(win)
+
+	bounds := win.Bounds()
+	notation := r.notation
+	if notation == "" {
+		notation = "No notes were heard"
+	}
+	lines := strings.Split(notation, "\n")
+	if len(lines) > 8 {
+		lines = append(lines[:7], "...")
+	}
+	txt := text.New(pixel.V(40, bounds.H()-60), ui.TextAtlas)
+	txt.Color = colornames.Black
+	fmt.Fprint(txt, strings.Join(lines, "\n"))
+	txt.Draw(win, pixel.IM)
+
+	fl := ui.FlexRows(pixel.R(0, 0, bounds.W(), bounds.H()*0.5), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, 5)
+	if r.err != nil {
+		ui.Label(win, fl(0), r.err.Error(), colornames.Red)
+	} else {
+		ui.Label(win, fl(0), "Name of the song:", colornames.Black)
+	}
+	save := ui.TextField(win, fl(1), &r.Name)
+	save = ui.Button(win, fl(2), "Save") || save
+	if save && r.notation != "" && strings.TrimSpace(r.Name) != "" {
+		r.err = config.AddSong(config.Song{
+			Name:  uniqueSongName(strings.TrimSpace(r.Name)),
+			Notes: r.notation,
+			Tempo: r.BPM,
+		})
+		if r.err == nil {
+			return NewModeMenu(len(config.Songs) - 1)
+		}
+		slog.Error("Failed to save song", "err", r.err)
+	}
+	if ui.Button(win, fl(3), "Record again") {
+		if err := r.start(); err != nil {
+			return NewErrorScene("Failed to start recording", err, r)
+		}
+	}
+	if ui.Button(win, fl(4), "← back") {
+		return &MainMenu{}
+	}
+	return r
+}
+
+// Add number to the name if there is already a song with it
+func uniqueSongName(name string) string {
+	taken := make(map[string]bool)
+	for _, s := range config.Songs {
+		taken[s.Name] = true
+	}
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return unique
+}
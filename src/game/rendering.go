@@ -3,159 +3,16 @@ package game
 import (
 	"fmt"
 	"image/color"
+	"log/slog"
 
-	"github.com/bunyk/fasolasi/src/config"
-	"github.com/bunyk/fasolasi/src/notes"
 	"github.com/bunyk/fasolasi/src/ui"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
-	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
 	"golang.org/x/image/colornames"
 )
 
-func time2X(width, time, currentTime float64) float64 {
-	return width * (config.TimeLinePosition - (currentTime-time)*config.NoteSPS)
-}
-
-func renderNotes(win *pixelgl.Window, song []notes.SongNote, played []playedNote, time float64) {
-	width := win.Bounds().W()
-	ybase := float64(win.Bounds().H()/2 - config.NoteRadius*4)
-
-	for _, note := range song {
-		renderNote(win, time, width, ybase, false, note)
-	}
-	for _, note := range played {
-		renderNote(win, time, width, ybase, note.Correct, note.SongNote)
-	}
-}
-
-var rainbow = []color.Color{
-	colornames.Red,
-	colornames.Orange,
-	colornames.Yellow,
-	colornames.Green,
-	colornames.Blue,
-	colornames.Violet,
-}
-
-func renderNote(win *pixelgl.Window, time, width, ybase float64, colorful bool, note notes.SongNote) {
-	if note.Pitch.Name == "p" {
-		return
-	}
-	end := note.End()
-	if end < 0.0 {
-		end = time
-	}
-	endX := time2X(width, end, time)
-	if endX < 0 { // invisible already
-		return
-	}
-	startX := time2X(width, note.Time, time)
-	if startX > width { // still invisible
-		return
-	}
-	ycenter := ybase + note.Pitch.Bottom*config.NoteRadius*2
-
-	imd := imdraw.New(nil)
-	imd.EndShape = imdraw.SharpEndShape
-	if note.Pitch.HasAdditionalLine() {
-		imd.Color = colornames.Black
-		imd.Push(
-			pixel.V(startX-config.NoteRadius*2, ycenter),
-			pixel.V(endX+config.NoteRadius*2, ycenter),
-		)
-		imd.Line(1)
-	}
-	border := 0.0
-	var textColor color.Color = colornames.White
-	if colorful {
-		imd.Color = rainbow[(10+int(note.Pitch.Bottom*4))%len(rainbow)]
-		textColor = oppositeColor(imd.Color)
-	} else {
-		if !note.Pitch.IsHalf { // white key
-			imd.Color = colornames.White
-			textColor = colornames.Black
-			imd.Push(
-				pixel.V(startX+1, ycenter-config.WhiteNoteWidth*config.NoteRadius+1),
-				pixel.V(endX-1, ycenter+config.WhiteNoteWidth*config.NoteRadius-1),
-			)
-			imd.Rectangle(0)
-			border = 2.0
-		}
-		imd.Color = colornames.Black
-	}
-
-	height := config.WhiteNoteWidth
-	if note.Pitch.IsHalf {
-		height = config.BlackNoteWidth
-	}
-	corner1 := pixel.V(startX+1, ycenter-height*config.NoteRadius+1)
-	corner2 := pixel.V(endX-1, ycenter+height*config.NoteRadius-1)
-	imd.Push(corner1, corner2)
-	imd.Rectangle(border)
-	imd.Draw(win)
-
-	ui.Label(win, pixel.Rect{Min: corner1, Max: corner2}, note.Pitch.Title(), textColor)
-}
-
-func oppositeColor(c color.Color) color.Color {
-	r, g, b, _ := c.RGBA()
-	return color.RGBA{uint8(255 - r), uint8(255 - g), uint8(255 - b), 255}
-}
-
-func renderProgress(win *pixelgl.Window, progress float64) {
-	if progress > 1.0 {
-		progress = 1.0
-	}
-	if progress < 0.0 {
-		progress = 0.0
-	}
-	imd := imdraw.New(nil)
-	width := win.Bounds().W()
-
-	imd.Color = colornames.Black
-	imd.Push(
-		pixel.V(2, 2),
-		pixel.V(width-2, 10),
-	)
-	imd.Rectangle(1)
-
-	imd.Color = colornames.Red
-	imd.Push(
-		pixel.V(2, 2),
-		pixel.V(2+(width-5)*progress, 9),
-	)
-	imd.Rectangle(0)
-
-	imd.Draw(win)
-}
-
-func renderNoteLines(win *pixelgl.Window) {
-	imd := imdraw.New(nil)
-	imd.Color = colornames.Black
-
-	width := win.Bounds().W()
-	height := win.Bounds().H()
-
-	ybase := float64(height/2 - config.NoteRadius*4)
-	for i := 0; i < 5; i++ {
-		imd.Push(
-			pixel.V(0, ybase+float64(i)*config.NoteRadius*2),
-			pixel.V(width, ybase+float64(i)*config.NoteRadius*2),
-		)
-		imd.Line(1)
-	}
-	imd.Push(
-		pixel.V(width*config.TimeLinePositionLatency, 0),
-		pixel.V(width*config.TimeLinePositionLatency, height),
-	)
-	imd.Line(3)
-
-	imd.Draw(win)
-}
-
-func renderScore(win *pixelgl.Window, score int, big bool) {
+func renderScore(win ui.Window, score int, big bool) {
 	scoreTxt := text.New(pixel.ZV, ui.TextAtlas)
 	scoreTxt.Color = colornames.Black
 	scoreTxt.Clear()
@@ -169,29 +26,15 @@ func renderScore(win *pixelgl.Window, score int, big bool) {
 	scoreTxt.Draw(win, pos)
 }
 
-func hightLightNote(win *pixelgl.Window, color color.Color, note notes.Pitch) {
-	imd := imdraw.New(nil)
-	width := win.Bounds().W()
-	ybase := float64(win.Bounds().H()/2 - config.NoteRadius*4)
-	if note.Name == "p" {
-		return
-	}
-	ycenter := ybase + note.Bottom*config.NoteRadius*2
-	imd.Color = color
-
-	height := config.WhiteNoteWidth
-	if note.IsHalf {
-		height = config.BlackNoteWidth
-	}
-	imd.Push(
-		pixel.V(0, ycenter-height*config.NoteRadius+1),
-		pixel.V(width, ycenter+height*config.NoteRadius-1),
-	)
-	imd.Rectangle(0)
-	imd.Draw(win)
+// Render line of text under the score
+func renderStatus(win ui.Window, line int, status string) {
+	txt := text.New(pixel.ZV, ui.TextAtlas)
+	txt.Color = colornames.Black
+	fmt.Fprint(txt, status)
+	txt.Draw(win, pixel.IM.Moved(pixel.V(0, win.Bounds().H()-80-40*float64(line))))
 }
 
-func soundVisualization(win *pixelgl.Window, col color.Color, data [][2]float64) {
+func soundVisualization(win ui.Window, col color.Color, data [][2]float64) {
 	imd := imdraw.New(nil)
 	imd.Color = col
 	width := win.Bounds().W()
@@ -212,13 +55,17 @@ var recorderSprite *pixel.Sprite
 func init() {
 	pic, err := ui.LoadPicture("sprites/recorder.png")
 	if err != nil {
-		panic(err)
+		slog.Error("Failed to load recorder sprite", "err", err)
+		return
 	}
 
 	recorderSprite = pixel.NewSprite(pic, pic.Bounds())
 }
 
-func renderFingering(win *pixelgl.Window) {
+func renderFingering(win ui.Window) {
+	if recorderSprite == nil {
+		return
+	}
 	scale := win.Bounds().H() / recorderSprite.Frame().H()
 	recorderSprite.Draw(win, pixel.IM.
 		Scaled(pixel.ZV, scale).
@@ -226,7 +73,7 @@ func renderFingering(win *pixelgl.Window) {
 	)
 }
 
-func renderMessage(win *pixelgl.Window, msg string) {
+func renderMessage(win ui.Window, msg string) {
 	txt := text.New(pixel.ZV, ui.TextAtlas)
 	txt.Color = colornames.Black
 	fmt.Fprintln(txt, msg)
@@ -234,3 +81,15 @@ func renderMessage(win *pixelgl.Window, msg string) {
 	pos = pixel.IM.Moved(win.Bounds().Center().Sub(txt.Bounds().Center()))
 	txt.Draw(win, pos)
 }
+
+// Text scaled down inside the box, over a light background with the given opacity
+func renderTextBox(win ui.Window, box pixel.Rect, txt *text.Text, scale, opacity, padding float64) {
+	imd := imdraw.New(nil)
+	imd.Color = pixel.Alpha(opacity)
+	imd.Push(box.Min, box.Max)
+	imd.Rectangle(0)
+	imd.Draw(win)
+	b := txt.Bounds()
+	// text starts from the dot at its first line, so move it down from the top of the box
+	txt.Draw(win, pixel.IM.Moved(pixel.V(-b.Min.X, -b.Max.Y)).Scaled(pixel.ZV, scale).Moved(pixel.V(box.Min.X+padding, box.Max.Y-padding)))
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/gameplay"
+	"github.com/bunyk/fasolasi/src/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// Input of the session, from the player or from the replay
+type Input interface {
+	Next(win ui.Window) gameplay.Frame
+}
+
+// Notes of the player, and keys to control the session
+type liveInput struct {
+	*player
+	lastUpdate time.Time
+}
+
+func newLiveInput() (*liveInput, error) {
+	p, err := newPlayer()
+	if err != nil {
+		return nil, err
+	}
+	return &liveInput{player: p, lastUpdate: time.Now()}, nil
+}
+
+func (li *liveInput) Next(win ui.Window) gameplay.Frame {
+	now := time.Now()
+	f := gameplay.Frame{
+		DT:    now.Sub(li.lastUpdate).Seconds(),
+		Pitch: li.Pitch(win),
+		Pause: win.JustPressed(ui.KeySpace),
+		Stop:  win.JustPressed(ui.KeyEscape),
+	}
+	li.lastUpdate = now
+	return f
+}
+
+// Frames of the recorded session, one by one
+type replayInput struct {
+	frames []gameplay.Frame
+	cursor int
+}
+
+func (ri *replayInput) Next(win ui.Window) gameplay.Frame {
+	if win.JustPressed(ui.KeyEscape) || ri.cursor >= len(ri.frames) {
+		return gameplay.Frame{Stop: true}
+	}
+	ri.cursor++
+	return ri.frames[ri.cursor-1]
+}
+
+// Replay is everything needed to play the session again: its settings and input
+type Replay struct {
+	Song          config.Song       `yaml:"song"` // copy of the song, in case it is edited or missing
+	Mode          string            `yaml:"mode"`
+	BPM           int               `yaml:"bpm"` // at the start of the session
+	Difficulty    config.Difficulty `yaml:"difficulty"`
+	Metronome     bool              `yaml:"metronome"` // with metronome song starts after the count-in
+	Melody        bool              `yaml:"melody"`    // played by the synthesizer together with the player
+	Accompaniment bool              `yaml:"accompaniment"`
+
+	// Section for the practice mode
+	From    int  `yaml:"from,omitempty"`
+	To      int  `yaml:"to,omitempty"`
+	CountIn bool `yaml:"count_in,omitempty"`
+
+	Frames []gameplay.Frame `yaml:"frames,flow"`
+}
+
+func LoadReplay(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Replay
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse replay %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Save replay to the replays directory, returns path of the file
+func (r *Replay) Save() (string, error) {
+	if err := os.MkdirAll(config.ReplaysDir, 0755); err != nil {
+		return "", err
+	}
+	name := time.Now().Format("2006-01-02 15-04-05") + " " + strings.ReplaceAll(r.Song.Name, "/", "-") + ".yaml"
+	path := filepath.Join(config.ReplaysDir, name)
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// NewReplay plays the recorded session again
+func NewReplay(r *Replay) ui.Scene {
+	s, err := setupReplay(r, &replayInput{frames: r.Frames})
+	if err != nil {
+		return NewErrorScene("Failed to play the replay", err, nil)
+	}
+	return s
+}
+
+func setupReplay(r *Replay, input Input) (*Session, error) {
+	songID := -1 // recorded song is played anyway, this is only to play it again from the menus
+	for i, s := range config.Songs {
+		if reflect.DeepEqual(s, r.Song) { // tempo, transposition or accompaniment could also be edited since the recording
+			songID = i
+		}
+	}
+	return setupSession(songID, *r, input)
+}
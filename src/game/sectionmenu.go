@@ -0,0 +1,154 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+// Menu to select section of the song to practice
+type SectionMenu struct {
+	SongID   int
+	From, To int // indexes of the first and the last selected notes
+	CountIn  bool
+	song     []notes.SongNote
+	picking  bool // From is selected, waiting for To
+}
+
+func NewSectionMenu(songID int) ui.Scene {
+	song, err := config.Songs[songID].ParseNotes(1.0)
+	if err != nil {
+		return NewErrorScene("Failed to read the song", err, NewModeMenu(songID))
+	}
+	return &SectionMenu{
+		SongID:  songID,
+		From:    0,
+		To:      len(song) - 1,
+		CountIn: true,
+		song:    song,
+	}
+}
+
+func (sm *SectionMenu) Loop(win ui.Window) ui.Scene {
+	win.Clear(config.BackgroundColor)
+	ui.Prepare()
+	defer ui.Finish(win)
+
+	bounds := win.Bounds()
+	preview := pixel.R(40, bounds.H()*0.55, bounds.W()-40, bounds.H()-60)
+	hovered := renderSongPreview(win, preview, sm.song, sm.From, sm.To, win.MousePosition())
+	if hovered >= 0 && win.JustPressed(ui.MouseButtonLeft) {
+		if sm.picking {
+			sm.To = hovered
+			if sm.To < sm.From {
+				sm.From, sm.To = sm.To, sm.From
+			}
+		} else {
+			sm.From, sm.To = hovered, hovered
+		}
+		sm.picking = !sm.picking
+	}
+	hint := "Click the first note of the section"
+	if sm.picking {
+		hint = "Click the last note of the section"
+	}
+	ui.Label(win, pixel.R(0, preview.Max.Y, bounds.W(), bounds.H()), hint, colornames.Black)
+
+	// notes could also be picked without the mouse
+	fl := ui.FlexRows(pixel.R(0, 0, bounds.W(), preview.Min.Y), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing/2, 5)
+	if change := ui.Spinner(win, fl(0), fmt.Sprintf("First note: %s", sm.noteLabel(sm.From))); change != 0 {
+		sm.From = sm.step(sm.From, change)
+		if sm.From > sm.To {
+			sm.To = sm.From
+		}
+		sm.picking = false
+	}
+	if change := ui.Spinner(win, fl(1), fmt.Sprintf("Last note: %s", sm.noteLabel(sm.To))); change != 0 {
+		sm.To = sm.step(sm.To, change)
+		if sm.To < sm.From {
+			sm.From = sm.To
+		}
+		sm.picking = false
+	}
+	if ui.Button(win, fl(2), onOff("Count-in", sm.CountIn)) {
+		sm.CountIn = !sm.CountIn
+	}
+	if ui.Button(win, fl(3), "Start practice") {
+		song := config.Songs[sm.SongID]
+		difficulty := config.Difficulties[config.CurrentProfile.DifficultyIndex()]
+		return NewPracticeSession(sm.SongID, config.CurrentProfile.Tempo(song), sm.From, sm.To, sm.CountIn, difficulty)
+	}
+	if ui.Button(win, fl(4), "← back") || win.JustPressed(ui.KeyEscape) {
+		return NewModeMenu(sm.SongID)
+	}
+	return sm
+}
+
+// Number and name of the note
+func (sm *SectionMenu) noteLabel(i int) string {
+	return fmt.Sprintf("%d (%s)", i+1, sm.song[i].Pitch.Name)
+}
+
+// Next or previous note from i in the direction, skipping pauses
+func (sm *SectionMenu) step(i, direction int) int {
+	for j := i + direction; j >= 0 && j < len(sm.song); j += direction {
+		if sm.song[j].Pitch.Name != "p" {
+			return j
+		}
+	}
+	return i
+}
+
+// Draw whole song in the given rectangle, highlighting notes from..to.
+// Returns index of note under the cursor, or -1.
+func renderSongPreview(win ui.Window, location pixel.Rect, song []notes.SongNote, from, to int, cursor pixel.Vec) int {
+	if len(song) == 0 {
+		return -1
+	}
+	start := song[0].Time
+	length := song[len(song)-1].End() - start
+	lineInterval := location.H() / 8 // notes go from one line below the staff to three lines above it
+	ybase := location.Min.Y + lineInterval
+	x := func(t float64) float64 {
+		return location.Min.X + (t-start)/length*location.W()
+	}
+
+	imd := imdraw.New(nil)
+	imd.Color = config.SelectionColor
+	imd.Push(
+		pixel.V(x(song[from].Time), location.Min.Y),
+		pixel.V(x(song[to].End()), location.Max.Y),
+	)
+	imd.Rectangle(0)
+
+	imd.Color = colornames.Black
+	for i := 0; i < 5; i++ {
+		y := ybase + float64(i+1)*lineInterval
+		imd.Push(pixel.V(location.Min.X, y), pixel.V(location.Max.X, y))
+		imd.Line(1)
+	}
+
+	hovered := -1
+	for i, note := range song {
+		if note.Pitch.Name == "p" {
+			continue
+		}
+		y := ybase + (note.Pitch.Bottom+1)*lineInterval
+		r := pixel.R(x(note.Time), y-lineInterval/3, x(note.End()), y+lineInterval/3)
+		if r.Contains(cursor) {
+			hovered = i
+			imd.Color = colornames.Red
+		} else {
+			imd.Color = colornames.Black
+		}
+		imd.Push(r.Min, r.Max)
+		imd.Rectangle(0)
+	}
+	imd.Draw(win)
+	return hovered
+}
@@ -2,268 +2,407 @@ package game
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"time"
 
+	"github.com/bunyk/fasolasi/src/audio"
 	"github.com/bunyk/fasolasi/src/config"
 	"github.com/bunyk/fasolasi/src/ear"
+	"github.com/bunyk/fasolasi/src/gameplay"
+	"github.com/bunyk/fasolasi/src/highway"
 	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/sheet"
+	"github.com/bunyk/fasolasi/src/spectrum"
 	"github.com/bunyk/fasolasi/src/ui"
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 	"golang.org/x/image/colornames"
 )
 
+// Session is the game state with window, sound and microphone
 type Session struct {
-	Song             []notes.SongNote
-	SongID           int
-	ModeName         string
-	BPM              int
-	Played           []playedNote
-	currentlyPlaying notes.Pitch
-	Score            float64
-	PlayToStart      float64   // if this is < 1.0 game is not started yet
-	Start            time.Time // time of start of the session
-	Duration         float64   // session duration, progress of song in seconds
-	SongDuration     float64   // Duration of the song in seconds
-	SongCursor       int       // number of passsed notes in song
-	LastUpdateTime   time.Time // time of last update
-	updateMode       func(dt float64, note notes.Pitch)
-	ear              *ear.Ear // For audio input
-	PointsParticles  *ParticleSystem
+	*gameplay.State
+	SongID          int
+	input           Input
+	ear             *ear.Ear // to show the sound, nil in replays and without microphone
+	replay          *Replay  // settings and input, to watch the session again
+	recording       bool     // input is recorded to the replay
+	PointsParticles *ParticleSystem
+	scroll          *sheet.Scroll // nil when notes are shown as piano roll
+	fifths          int           // in the key signature of the song, to name the notes
+
+	metronome *gameplay.Metronome // nil when turned off
+	backing   *audio.Synth        // accompaniment, nil if there is none
+	track     *audio.Track        // backing track, nil if there is none
+	clock     audio.Clock         // to play backing in sync with Duration
+	round     int                 // last round of the song, to restart metronome with it
+	frameTime float64             // average, in seconds, for the debug overlay
+
+	showTrace   bool                 // draw line of the pitch heard
+	trace       []highway.TracePoint // pitch heard during the visible part of the song
+	spectrogram *highway.Spectrogram // nil when turned off, or there is no microphone
+	analyzer    *spectrum.Analyzer   // for the spectrogram
 }
 
-type playedNote struct {
-	notes.SongNote
-	Correct bool
+// Size of the spectrogram: rows, and the time of a column, which is one buffer of the microphone
+const (
+	spectrogramRows = 128
+	spectrogramStep = float64(config.MicrophoneBufferLength) / config.MicrophoneSampleRate
+)
+
+// Spectrogram is visible from the left of the screen to the time line
+func newSpectrogram() *highway.Spectrogram {
+	return highway.NewSpectrogram(spectrogramRows, spectrogramStep, config.TimeLinePosition/config.NoteSPS)
 }
 
-func NewSession(songID int, mode string, bpm int) ui.Scene {
-	fmt.Println("Initializing game session for", config.Songs[songID].Name)
-	song, err := config.Songs[songID].ParseNotes(240.0 / float64(bpm))
-	fmt.Println(song)
+func NewSession(songID int, mode string, bpm int, difficulty config.Difficulty) ui.Scene {
+	s, err := setupSession(songID, Replay{
+		Song:          config.Songs[songID],
+		Mode:          mode,
+		BPM:           bpm,
+		Difficulty:    difficulty,
+		Metronome:     config.CurrentProfile.Metronome && mode != "training",
+		Melody:        config.CurrentProfile.Melody,
+		Accompaniment: config.CurrentProfile.Accompaniment,
+	}, nil)
 	if err != nil {
-		log.Fatal(err)
+		return NewErrorScene("Failed to start the game", err, NewModeMenu(songID))
 	}
-	s := &Session{
-		Played:          make([]playedNote, 0, 100),
-		Song:            append([]notes.SongNote{{Duration: 1.0, Time: -1.0, Pitch: notes.C}}, song...),
-		SongID:          songID,
-		ModeName:        mode,
-		BPM:             bpm,
-		ear:             ear.New(config.MicrophoneSampleRate, config.MicrophoneBufferLength),
-		PointsParticles: NewParticleSystem("sprites/points.png", 32, 32),
-	}
-	if mode == "challenge" {
-		s.updateMode = s.challengeUpdate
-	} else {
-		s.updateMode = s.trainingUpdate
-	}
-	s.SongDuration = song[len(song)-1].End()
-	s.LastUpdateTime = time.Now()
 	return s
 }
 
-func (s Session) Finished() bool {
-	return s.SongCursor >= len(s.Song)
+// Create session with the given settings, for r.Song. Without input session is played live, and recorded for the replay.
+// Song ID is where the song is in config.Songs, or -1 when a replay has a song that is not there anymore.
+func setupSession(songID int, r Replay, input Input) (*Session, error) {
+	if r.Mode == "practice" {
+		return practiceSession(songID, r, input)
+	}
+	slog.Info("Starting game session", "song", r.Song.Name, "mode", r.Mode, "bpm", r.BPM, "replay", input != nil)
+	song, err := r.Song.ParseNotes(240.0 / float64(r.BPM))
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Song parsed", "notes", len(song))
+	if len(song) == 0 {
+		return nil, fmt.Errorf("Song %s has no notes", r.Song.Name)
+	}
+	shift := 0.0
+	if r.Metronome {
+		shift = countInShift(song, r.BPM)
+		shiftNotes(song, shift)
+	}
+	s, err := newSession(songID, r, input, song)
+	if err != nil {
+		return nil, err
+	}
+	if r.Mode != "training" { // in training timeline stops, so there is nothing to play along
+		voices, err := songVoices(r.Song, song, r.Melody, r.Accompaniment, r.BPM, shift)
+		if err != nil {
+			return nil, err
+		}
+		if len(voices) > 0 {
+			s.backing = audio.NewSynth(voices, &s.clock)
+		}
+		if bt := r.Song.BackingTrack; bt != nil {
+			speed := float64(r.BPM) / float64(r.Song.TargetBPM())
+			s.track, err = audio.OpenTrack(bt.File, bt.Offset, song[0].Time, speed, &s.clock)
+			if err != nil {
+				slog.Warn("Failed to open backing track", "file", bt.File, "err", err)
+			}
+		}
+	}
+	return s, nil
 }
 
-func (s *Session) moveSongCursor() {
-	// skip played notes
-	for !s.Finished() && s.Song[s.SongCursor].End() < s.Duration {
-		s.SongCursor += 1
-	}
+// How much to move song, so there is a whole bar before the first note, for the metronome to count in
+func countInShift(song []notes.SongNote, bpm int) float64 {
+	return math.Max(0, 240.0/float64(bpm)-song[0].Time)
 }
 
-func (s *Session) nextNote() notes.SongNote {
-	s.moveSongCursor()
-	if s.Finished() {
-		return notes.SongNote{}
+func shiftNotes(song []notes.SongNote, shift float64) {
+	for i := range song {
+		song[i].Time += shift
 	}
-	return s.Song[s.SongCursor]
 }
 
-func (s *Session) currentNote() notes.Pitch {
-	s.moveSongCursor()
-
-	// no notes to play left
-	if s.Finished() {
-		return notes.Pause
+// Voices for the synthesizer to play: optionally accompaniment, and the melody itself
+func songVoices(s config.Song, melody []notes.SongNote, withMelody, withAccompaniment bool, bpm int, shift float64) ([]audio.Voice, error) {
+	var voices []audio.Voice
+	if withMelody {
+		voices = append(voices, audio.Voice{Notes: melody, Volume: config.MelodyVolume})
 	}
-	nn := s.Song[s.SongCursor]
-
-	// should be playing some note right now
-	if nn.Time < s.Duration {
-		return nn.Pitch
+	if !withAccompaniment {
+		return voices, nil
+	}
+	accompaniment, err := s.ParseAccompaniment(240.0 / float64(bpm))
+	if err != nil {
+		return nil, err
+	}
+	for _, voice := range accompaniment {
+		shiftNotes(voice, shift)
+		voices = append(voices, audio.Voice{
+			Notes:  voice,
+			Octave: config.AccompanimentOctave,
+			Volume: config.AccompanimentVolume,
+		})
 	}
-	// otherwise - not yet playing anything
-	return notes.Pause
+	return voices, nil
 }
 
-// Notes don't stop, and you need to hit correct ones in time
-func (s *Session) challengeUpdate(dt float64, note notes.Pitch) {
-	s.Duration = time.Since(s.Start).Seconds()
-	playingCorrectly := note == s.currentNote()
-	if note.Name != "p" {
-		if playingCorrectly {
-			s.Score += dt
-		} else {
-			s.Score -= dt
-		}
-		if len(s.Played) == 0 || s.Played[len(s.Played)-1].End() > 0 { // no note currently playing
-			s.Played = append(s.Played, playedNote{
-				SongNote: notes.SongNote{ // create new note
-					Time:     s.Duration,
-					Pitch:    note,
-					Duration: -1.0,
-				},
-				Correct: playingCorrectly,
-			})
-		} else if s.Played[len(s.Played)-1].Pitch != note || s.Played[len(s.Played)-1].Correct != playingCorrectly { // note changed
-			s.Played[len(s.Played)-1].Duration = s.Duration - s.Played[len(s.Played)-1].Time // end current one
-			s.Played = append(s.Played, playedNote{
-				SongNote: notes.SongNote{ // create new note
-					Time:     s.Duration,
-					Pitch:    note,
-					Duration: -1.0,
-				},
-				Correct: playingCorrectly,
-			})
-		}
-	} else { // no note
-		if len(s.Played) > 0 && s.Played[len(s.Played)-1].End() < 0 { // there is a note still playing
-			s.Played[len(s.Played)-1].Duration = s.Duration - s.Played[len(s.Played)-1].Time // end it
+func newSession(songID int, r Replay, input Input, song []notes.SongNote) (*Session, error) {
+	particles, err := NewParticleSystem("sprites/points.png", 32, 32)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		State:           gameplay.New(r.Mode, r.BPM, r.Difficulty, song),
+		SongID:          songID,
+		input:           input,
+		replay:          &r,
+		PointsParticles: particles,
+	}
+	s.round = s.Round
+	if input == nil {
+		live, err := newLiveInput()
+		if err != nil {
+			return nil, err
 		}
+		s.input = live
+		s.ear = live.ear
+		s.recording = true
+		r.Frames = nil
 	}
-
-	if len(s.Played) > 2 && s.Played[0].End() < s.Duration-config.TimeLinePosition/config.NoteSPS { // note not visible
-		s.Played = s.Played[1:] // remove
+	if r.Metronome {
+		s.metronome = gameplay.NewMetronome(r.BPM, song[0].Time)
+	}
+	if s.fifths, err = r.Song.Fifths(); err != nil {
+		slog.Warn("Failed to read key of the song", "err", err)
 	}
+	if config.CurrentProfile.SheetMusic {
+		s.setupScroll()
+	}
+	s.showTrace = config.CurrentProfile.PitchTrace
+	if config.CurrentProfile.Spectrogram && s.ear != nil {
+		s.spectrogram = newSpectrogram()
+		s.analyzer = spectrum.NewAnalyzer(config.MicrophoneSampleRate, len(s.ear.MicBuffer))
+	}
+	return s, nil
 }
 
-// Notes move only while you play correct note, to progress - play all the notes in correct orders.
-// Obeying durations is optional.
-func (s *Session) trainingUpdate(dt float64, note notes.Pitch) {
-	nn := s.nextNote()
-	if note.Name != "p" {
-		if len(s.Played) > 0 { // we were already playing some note
-			if s.Played[0].Pitch == note { // still playing it
-				s.Duration += dt
-				if s.Duration > s.Played[0].End() { // Should have stopped already
-					s.Score -= s.Duration - s.Played[0].End() // Decrease score
-					s.Duration = s.Played[0].End()
-					s.Played[0].Correct = false
-				} else {
-					s.Score += dt
-				}
-				return // and that's it for continuing playing note
-			}
-		}
-		if note.Name == nn.Pitch.Name { // start playing current note
-			s.Played = []playedNote{{
-				SongNote: nn,
-				Correct:  true,
-			}}
-			s.Score += 1.0
-			s.Duration = nn.Time
-			s.SongCursor += 1 // Prepare for next note
-		}
-	} else { // no note, probably stopped playing
-		s.Played = nil
-		s.Duration = nn.Time // Move timeline to next note
-		if nn.Pitch.Name == "p" {
-			s.Score += 1.0
-			s.SongCursor += 1
-		}
+// Lay out notes of the session as sheet music
+func (s *Session) setupScroll() {
+	var err error
+	s.scroll, err = sheet.NewScroll(s.replay.Song, s.Song, s.BPM)
+	if err != nil {
+		slog.Warn("Failed to show sheet music, using piano roll", "err", err)
 	}
 }
 
-func (s *Session) Loop(win *pixelgl.Window) ui.Scene {
-	dt := time.Since(s.LastUpdateTime).Seconds()
-	s.LastUpdateTime = time.Now()
+// Staff to draw notes on
+func (s *Session) staff(bounds pixel.Rect) highway.Staff {
+	st := highway.PianoRoll(bounds)
+	if s.scroll != nil {
+		st = highway.SheetMusic(bounds)
+	}
+	st.Fifths = s.fifths
+	return st
+}
 
+func (s *Session) Loop(win ui.Window) ui.Scene {
 	// Input
-	kp := KeyboardPitch(win) // for silent debugging :)
-	if kp > 0.0 {
-		s.ear.Pitch = kp
+	frame := s.input.Next(win)
+	if s.recording {
+		s.replay.Frames = append(s.replay.Frames, frame)
+	}
+	if win.JustPressed(ui.KeyF3) {
+		showDebug = !showDebug
 	}
-	s.currentlyPlaying, _ = notes.GuessNote(s.ear.Pitch)
+	if s.frameTime == 0 {
+		s.frameTime = frame.DT
+	}
+	s.frameTime += (frame.DT - s.frameTime) * frameTimeSmoothing
 
+	// Processing
 	lastScore := s.Score
-	if s.PlayToStart < 1.0 {
-		if s.currentlyPlaying == notes.C { // Play c for one second to start
-			s.PlayToStart += dt
+	started, paused := s.Started(), s.Paused
+	if !s.Update(frame) {
+		return s.finishScene()
+	}
+	if s.Started() && !started {
+		slog.Debug("Session started")
+		if s.backing != nil {
+			audio.Play(s.backing)
 		}
-		if s.PlayToStart >= 1.0 {
-			fmt.Println("Go!")
-			s.Start = time.Now()
+		if s.track != nil {
+			audio.Play(s.track)
 		}
-	} else {
-		// Processing
-		if s.Finished() {
-			return &FinishScene{SongID: s.SongID, Mode: s.ModeName, Score: s.RoundedScore(), BPM: s.BPM}
-		} else {
-			s.updateMode(dt, s.currentlyPlaying)
+		if s.ear != nil {
+			// player waits for the first note, while the game plays the intro or count-in
+			wait := s.Song[1].Time - s.Duration - config.CalibrationMargin
+			s.ear.Calibrate(time.Duration(wait * float64(time.Second)))
+		}
+	}
+	if s.Paused != paused {
+		audio.Pause(s.Paused)
+	}
+	if s.Round != s.round { // practice started next pass
+		if s.metronome != nil {
+			s.metronome.Reset(s.BPM, s.Song[1].Time)
+		}
+		if s.scroll != nil {
+			s.setupScroll()
+		}
+		s.trace = nil // time starts again
+		if s.spectrogram != nil {
+			s.spectrogram = newSpectrogram()
+		}
+	}
+	s.round = s.Round
+	if s.Started() && !s.Paused {
+		s.clock.Set(s.Duration)
+		if s.metronome != nil {
+			if click, accent := s.metronome.Tick(s.Duration); click {
+				audio.Play(audio.Click(accent))
+				if s.ear != nil {
+					s.ear.HoldPitch(config.ClickHoldTime)
+				}
+			}
 		}
 	}
 	sDiff := s.Score - lastScore
 	if sDiff > 0.01 { // Score increased
 		s.spawnPointsParticles(win)
 	}
+	staff := s.staff(win.Bounds())
+	if s.Started() && !s.Paused {
+		s.listen(staff, win.Bounds().H(), frame.Pitch)
+	}
 
 	// Rendering
 	win.Clear(config.BackgroundColor)
-	soundVisualization(win, colornames.Blue, s.ear.MicBuffer)
-	hightLightNote(win, colornames.Salmon, s.currentlyPlaying)
-	renderNoteLines(win)
-	s.PointsParticles.UpdateAndRender(win, dt)
-	renderNotes(win, s.Song, s.Played, s.Duration)
+	if s.spectrogram != nil {
+		s.spectrogram.Draw(win, s.Duration)
+	}
+	if s.ear != nil {
+		soundVisualization(win, colornames.Blue, s.ear.MicBuffer)
+	}
+	highway.Highlight(win, staff, colornames.Salmon, s.Playing)
+	highway.Lines(win, staff)
+	s.PointsParticles.UpdateAndRender(win, frame.DT)
+	if s.scroll != nil {
+		highway.Notes(win, staff, nil, s.Played, s.Duration)
+		width := win.Bounds().W()
+		s.scroll.Draw(win, staff.Bottom, staff.Space, func(t float64) float64 {
+			return highway.TimeX(width, t, s.Duration)
+		})
+	} else {
+		highway.Notes(win, staff, s.Song, s.Played, s.Duration)
+	}
+	if s.showTrace {
+		highway.PitchTrace(win, staff, colornames.Darkblue, s.trace, s.Duration)
+	}
 	if config.ShowFingering {
 		renderFingering(win) // TODO: pass here note that needs to be played
 	}
-	renderProgress(win, s.Duration/s.SongDuration)
+	highway.Progress(win, s.Duration/s.SongDuration)
 
-	if s.PlayToStart >= 1.0 {
+	if s.Paused {
+		renderMessage(win, "Paused, press space to continue")
+	} else if s.Started() {
 		renderScore(win, s.RoundedScore(), s.Finished())
+		if s.Mode != "training" {
+			renderStatus(win, 0, fmt.Sprintf("%s  x%d  combo: %d", s.LastJudgement, s.Scorer.Multiplier(), s.Scorer.Combo))
+		}
+		if s.Follow != nil {
+			renderStatus(win, 1, fmt.Sprintf("%.0f bpm", float64(s.BPM)*s.Follow.Rate))
+		}
+		if s.Practice != nil {
+			renderStatus(win, 1, fmt.Sprintf("%d bpm, clean passes: %d", s.BPM, s.Practice.Passes))
+		}
+		if (s.metronome != nil || s.Practice != nil && s.Practice.CountIn) && s.Duration < s.Song[1].Time {
+			renderMessage(win, fmt.Sprint(int(s.Duration*float64(s.BPM)/60)+1)) // count in
+		}
 	} else if s.PlayToStart >= 0.8 {
 		renderMessage(win, "Let's go!")
 	} else {
 		renderMessage(win, "Play C for one second to start")
 	}
+	if !s.recording {
+		renderStatus(win, 2, "Replay, press Escape to stop")
+	}
+	if showDebug {
+		renderDebug(win, s.debugLines(frame.DT, frame.Pitch))
+	}
 
 	win.Update()
 	return s
 }
 
-func (s *Session) spawnPointsParticles(win *pixelgl.Window) {
+// Remember the pitch heard for the trace, and the sound for the spectrogram
+func (s *Session) listen(staff highway.Staff, height, pitch float64) {
+	if s.showTrace {
+		visible := config.TimeLinePosition / config.NoteSPS // seconds from the left of the screen to the time line
+		for len(s.trace) > 0 && s.trace[0].Time < s.Duration-visible {
+			s.trace = s.trace[1:]
+		}
+		s.trace = append(s.trace, highway.TracePoint{Time: s.Duration, Frequency: pitch})
+	}
+	if s.spectrogram != nil && s.spectrogram.Due(s.Duration) {
+		magnitudes := s.analyzer.Spectrum(s.ear.MicBuffer)
+		s.spectrogram.Add(staff, height, s.Duration, func(frequency float64) float64 {
+			return s.analyzer.At(magnitudes, frequency)
+		})
+	}
+}
+
+func (s *Session) spawnPointsParticles(win ui.Window) {
 	width := win.Bounds().W()
 	height := win.Bounds().H()
-	ybase := float64(height/2 - config.NoteRadius*4)
 	src := pixel.V(
 		width*config.TimeLinePositionLatency,
-		ybase+s.currentlyPlaying.Bottom*config.NoteRadius*2,
+		s.staff(win.Bounds()).Y(s.Playing),
 	)
 	dst := pixel.V(0, height)
 
 	s.PointsParticles.Spawn(src, dst)
 }
 
-func (s Session) RoundedScore() int {
-	return int(s.Score * 100)
-}
-
-func KeyboardPitch(win *pixelgl.Window) float64 {
-	if win.Pressed(pixelgl.KeyA) {
-		return 523.25
+func (s *Session) finishScene() ui.Scene {
+	if s.Paused {
+		audio.Pause(false)
+	}
+	if s.backing != nil {
+		s.backing.Stop()
 	}
-	if win.Pressed(pixelgl.KeyS) {
-		return 587.33
+	if s.track != nil {
+		s.track.Stop()
 	}
-	if win.Pressed(pixelgl.KeyD) {
-		return 659.25
+	finished := s.Finished() // and not left with Escape
+	result := s.Finish()
+	if s.recording {
+		if path, err := s.replay.Save(); err != nil {
+			slog.Error("Failed to save replay", "err", err)
+		} else {
+			slog.Info("Replay saved", "file", path)
+		}
+		config.CurrentProfile.Played(s.replay.Song, config.Run{
+			Mode:       s.Mode,
+			BPM:        s.BPM,
+			Difficulty: s.Difficulty.Name,
+			Finished:   finished,
+			Score:      result.Score,
+			Grade:      result.Grade,
+		}, time.Now())
+		if err := config.CurrentProfile.Save(); err != nil {
+			slog.Error("Failed to save profile", "err", err)
+		}
 	}
-	if win.Pressed(pixelgl.KeyF) {
-		return 698.0
+	return &FinishScene{
+		SongID:     s.SongID,
+		Mode:       s.Mode,
+		BPM:        s.BPM,
+		Difficulty: s.Difficulty,
+		Result:     result,
+		Replay:     s.replay,
 	}
-	return -1.0
 }
@@ -0,0 +1,97 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/bunyk/fasolasi/src/audio"
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/ui"
+)
+
+type SettingsMenu struct {
+}
+
+func (sm *SettingsMenu) Loop(win ui.Window) ui.Scene {
+	win.Clear(config.BackgroundColor)
+	renderFingering(win)
+	ui.Prepare()
+	defer ui.Finish(win)
+
+	profile := &config.CurrentProfile
+	fl := ui.FlexRows(win.Bounds(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing/2, 11)
+
+	if change := ui.Spinner(win, fl(0), fmt.Sprintf("Volume: %.0f%%", profile.Volume*100)); change != 0 {
+		profile.Volume += float64(change) * 0.1
+		if profile.Volume < 0 {
+			profile.Volume = 0
+		}
+		if profile.Volume > 1 {
+			profile.Volume = 1
+		}
+		audio.SetVolume(profile.Volume, profile.Mute)
+	}
+	if ui.Button(win, fl(1), onOff("Sound", !profile.Mute)) {
+		profile.Mute = !profile.Mute
+		audio.SetVolume(profile.Volume, profile.Mute)
+	}
+	if ui.Button(win, fl(2), onOff("Metronome", profile.Metronome)) {
+		profile.Metronome = !profile.Metronome
+	}
+	if ui.Button(win, fl(3), onOff("Accompaniment", profile.Accompaniment)) {
+		profile.Accompaniment = !profile.Accompaniment
+	}
+	if ui.Button(win, fl(4), onOff("Melody during game", profile.Melody)) {
+		profile.Melody = !profile.Melody
+	}
+	view := "View: piano roll"
+	if profile.SheetMusic {
+		view = "View: sheet music"
+	}
+	if ui.Button(win, fl(5), view) {
+		profile.SheetMusic = !profile.SheetMusic
+	}
+	if ui.Button(win, fl(6), onOff("Spectrogram", profile.Spectrogram)) {
+		profile.Spectrogram = !profile.Spectrogram
+	}
+	if ui.Button(win, fl(7), onOff("Pitch trace", profile.PitchTrace)) {
+		profile.PitchTrace = !profile.PitchTrace
+	}
+	if ui.Button(win, fl(8), "Input: "+profile.Input) {
+		profile.Input = nextInput(profile.Input)
+		if err := SetupVoice(); err != nil {
+			profile.VoiceControl = false
+			return NewErrorScene("Failed to turn on voice control", err, sm)
+		}
+	}
+	if ui.Button(win, fl(9), onOff("Voice control", profile.VoiceControl)) {
+		profile.VoiceControl = !profile.VoiceControl
+		if err := SetupVoice(); err != nil {
+			profile.VoiceControl = false
+			return NewErrorScene("Failed to turn on voice control", err, sm)
+		}
+	}
+	if ui.Button(win, fl(10), "← back") || win.JustPressed(ui.KeyEscape) {
+		if err := profile.Save(); err != nil {
+			slog.Error("Failed to save profile", "err", err)
+		}
+		return &MainMenu{}
+	}
+	return sm
+}
+
+func onOff(label string, on bool) string {
+	if on {
+		return label + ": on"
+	}
+	return label + ": off"
+}
+
+func nextInput(input string) string {
+	for i, in := range config.Inputs {
+		if in == input {
+			return config.Inputs[(i+1)%len(config.Inputs)]
+		}
+	}
+	return config.Inputs[0]
+}
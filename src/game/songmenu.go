@@ -2,20 +2,46 @@ package game
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/aquilax/truncate"
 	"github.com/bunyk/fasolasi/src/config"
 	"github.com/bunyk/fasolasi/src/ui"
-	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
 )
 
+// Song browser: songs could be searched, sorted and filtered by tags, and the one under the cursor or focus is described on the right
 type SongMenu struct {
-	Offset int
+	Search  string
+	Tags    []string // selected, songs should have all of them
+	Offset  int      // index in found of the first song shown
+	entries []config.SongEntry
+	tags    []string           // of all the songs
+	found   []config.SongEntry // songs that match the search and tags, in order
+	rows    int                // songs that fit on the screen
+	focused int                // index in found of the song with keyboard focus in the last frame, -1 when it is on other widget
+	shown   int                // index in found of the song described
 }
 
+// Search and tags are kept when coming back to the list
+var (
+	lastSearch string
+	lastTags   []string
+)
+
 func NewSongMenu() *SongMenu {
-	return &SongMenu{}
+	sm := &SongMenu{
+		Search:  lastSearch,
+		Tags:    lastTags,
+		entries: config.SongEntries(),
+		focused: -1,
+	}
+	sm.tags = config.SongTags(sm.entries)
+	sm.filter()
+	return sm
 }
 
 func cleanupName(fn string) string {
@@ -27,8 +53,8 @@ func cleanupName(fn string) string {
 	)
 }
 
-func (sm *SongMenu) Loop(win *pixelgl.Window) ui.Scene {
-	if win.Pressed(pixelgl.KeyEscape) {
+func (sm *SongMenu) Loop(win ui.Window) ui.Scene {
+	if win.JustPressed(ui.KeyEscape) {
 		return &MainMenu{}
 	}
 	win.Clear(config.BackgroundColor)
@@ -36,47 +62,222 @@ func (sm *SongMenu) Loop(win *pixelgl.Window) ui.Scene {
 	ui.Prepare()
 	defer ui.Finish(win)
 
-	fl := ui.FlexRows(win.Bounds().Norm(), config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, config.MenuMaxItems)
+	bounds := win.Bounds()
+	list := pixel.R(160, 100, bounds.W()*0.57, bounds.H()-30) // search, tags and songs
+	info := pixel.R(list.Max.X+40, 100, bounds.W()-30, bounds.H()-30)
+	row := func(top float64) pixel.Rect { // of the button, under the top
+		return pixel.R(list.Min.X, top-config.MenuButtonHeight, list.Max.X, top)
+	}
+	tagsTop := list.Max.Y - config.MenuButtonHeight - 15
+	songsTop := tagsTop
+	if len(sm.tags) > 0 {
+		songsTop -= 40 + 15
+	}
+	sm.rows = int((songsTop - list.Min.Y + 10) / (config.MenuButtonHeight + 10))
+	focusSong := sm.handleScroll(win)
 
-	haveButtons := 0
-	if sm.Offset > 0 {
-		if ui.Button(win, fl(haveButtons), "↑ Up") {
-			sm.Offset-- // Go up
-		}
-		haveButtons++
+	if ui.TextField(win, row(list.Max.Y), &sm.Search) && len(sm.found) > 0 {
+		return NewModeMenu(sm.found[0].ID)
+	}
+	if sm.Search == "" {
+		ui.Label(win, row(list.Max.Y), "type to search", colornames.Gray)
+	}
+	if sm.Search != lastSearch {
+		sm.filter()
 	}
 
-	// we could have no more than config.MenuMaxItems buttons in menu
-	// Last button is "Back", so for songs we have MenuMaxItems - haveButtons - 1 remaining buttons
-	limit := config.MenuMaxItems - haveButtons - 1
-	showDown := false
-	if sm.Offset+limit <= len(config.Songs) { // we do not see end of the list, need "↓ Down" button
-		showDown = true
-		limit -= 1 // need one more slot for "Down button"
-	} else {
-		limit = len(config.Songs) - sm.Offset
+	order := config.CurrentProfile.SongSort
+	if order == "" {
+		order = config.SortName
+	}
+	sortRow := pixel.R(info.Min.X, list.Max.Y-config.MenuButtonHeight, info.Max.X, list.Max.Y)
+	if change := ui.Spinner(win, sortRow, "By "+order); change != 0 {
+		sm.setSort(order, change)
 	}
 
-	for i, song := range config.Songs[sm.Offset : sm.Offset+limit] {
-		if ui.Button(win, fl(haveButtons), cleanupName(song.Name)) {
-			return &ModeMenu{sm.Offset + i}
+	x := list.Min.X
+	for _, tag := range sm.tags {
+		label := "[ ] " + tag
+		if sm.selected(tag) {
+			label = "[x] " + tag
+		}
+		width := text.New(pixel.ZV, ui.TextAtlas).BoundsOf(label).W() + 30
+		if x+width > info.Max.X {
+			break // no place for the rest
 		}
-		haveButtons++
+		if ui.Button(win, pixel.R(x, tagsTop-40, x+width, tagsTop), label) {
+			sm.toggleTag(tag)
+		}
+		x += width + 10
 	}
 
-	if showDown {
-		if ui.Button(win, fl(haveButtons), "↓ Down") {
-			sm.Offset++         // Go down
-			if sm.Offset == 1 { // Otherwise it will look like first item in list is replaced but up button
-				sm.Offset++
-			}
-			fmt.Println("Down, new offset:", sm.Offset)
+	if len(sm.found) == 0 {
+		ui.Label(win, row(songsTop), "No songs found", colornames.Gray)
+	}
+	sm.focused = -1
+	hovered := -1
+	for i := sm.Offset; i < len(sm.found) && i < sm.Offset+sm.rows; i++ {
+		if i == focusSong {
+			ui.Focus()
+		}
+		if ui.Button(win, row(songsTop-float64(i-sm.Offset)*(config.MenuButtonHeight+10)), cleanupName(sm.found[i].Name)) {
+			return NewModeMenu(sm.found[i].ID)
+		}
+		if ui.Focused() {
+			sm.focused = i
+		}
+		if ui.Hovered() {
+			hovered = i
 		}
-		haveButtons++
 	}
-	if ui.Button(win, fl(haveButtons), "← Back") {
-		return &MainMenu{}
+	if hovered >= 0 {
+		sm.shown = hovered
+	} else if sm.focused >= 0 {
+		sm.shown = sm.focused
+	}
+	if sm.shown < len(sm.found) {
+		renderSongInfo(win, pixel.R(info.Min.X, list.Min.Y, info.Max.X, sortRow.Min.Y-15), sm.found[sm.shown])
 	}
 
+	back := pixel.R(list.Min.X, 30, list.Min.X+200, 30+config.MenuButtonHeight)
+	if ui.Button(win, back, "← Back") {
+		return &MainMenu{}
+	}
+	if len(sm.found) > sm.rows {
+		last := sm.Offset + sm.rows
+		ui.Label(win, pixel.R(back.Max.X, back.Min.Y, list.Max.X, back.Max.Y),
+			fmt.Sprintf("%d-%d of %d", sm.Offset+1, last, len(sm.found)), colornames.Black)
+	}
 	return sm
 }
+
+// List scrolls by the wheel and page keys, and when keyboard focus goes past its ends.
+// Returns index of the song that should get focus, or -1.
+func (sm *SongMenu) handleScroll(win ui.Window) int {
+	typed := func(k ui.Key) bool { return win.JustPressed(k) || win.Repeated(k) }
+	last := sm.Offset + sm.rows - 1
+	switch {
+	case win.MouseScroll().Y != 0:
+		sm.scroll(-int(win.MouseScroll().Y))
+	case typed(ui.KeyPageDown):
+		sm.scroll(sm.rows)
+	case typed(ui.KeyPageUp):
+		sm.scroll(-sm.rows)
+	case sm.focused >= 0 && sm.focused == last && last+1 < len(sm.found) && typed(ui.KeyDown):
+		sm.scroll(1)
+		ui.ConsumeKeys()
+		return last + 1
+	case sm.focused >= 0 && sm.focused == sm.Offset && sm.Offset > 0 && typed(ui.KeyUp):
+		sm.scroll(-1)
+		ui.ConsumeKeys()
+		return sm.focused - 1
+	}
+	return -1
+}
+
+// Move the list by the number of songs, up when it is negative
+func (sm *SongMenu) scroll(by int) {
+	sm.Offset += by
+	if sm.Offset > len(sm.found)-sm.rows {
+		sm.Offset = len(sm.found) - sm.rows
+	}
+	if sm.Offset < 0 {
+		sm.Offset = 0
+	}
+	slog.Debug("Song list scrolled", "offset", sm.Offset)
+}
+
+// Find songs again, after the search or tags are changed
+func (sm *SongMenu) filter() {
+	sm.found = config.FilterSongs(sm.entries, sm.Search, sm.Tags, config.CurrentProfile.SongSort)
+	sm.Offset, sm.shown = 0, 0
+	lastSearch, lastTags = sm.Search, sm.Tags
+}
+
+func (sm *SongMenu) setSort(order string, change int) {
+	i := 0
+	for j, s := range config.SongSorts {
+		if s == order {
+			i = j
+		}
+	}
+	config.CurrentProfile.SongSort = config.SongSorts[(i+change+len(config.SongSorts))%len(config.SongSorts)]
+	if err := config.CurrentProfile.Save(); err != nil {
+		slog.Error("Failed to save profile", "err", err)
+	}
+	sm.filter()
+}
+
+func (sm *SongMenu) selected(tag string) bool {
+	for _, t := range sm.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (sm *SongMenu) toggleTag(tag string) {
+	tags := make([]string, 0, len(sm.Tags)+1)
+	for _, t := range sm.Tags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	if !sm.selected(tag) {
+		tags = append(tags, tag)
+	}
+	sm.Tags = tags
+	sm.filter()
+}
+
+// Description of the song, on a light background
+func renderSongInfo(win ui.Window, location pixel.Rect, e config.SongEntry) {
+	const scale = 0.7
+	txt := text.New(pixel.ZV, ui.TextAtlas)
+	txt.Color = colornames.Black
+	fmt.Fprintln(txt, truncate.Truncate(e.Name, 26, "...", truncate.PositionEnd))
+	if len(e.Tags) > 0 {
+		fmt.Fprintln(txt, strings.Join(e.Tags, ", "))
+	}
+	fmt.Fprintln(txt)
+	if e.Err != nil {
+		txt.Color = colornames.Red
+		fmt.Fprintln(txt, "Could not be played:")
+		for _, line := range wrapText(e.Err.Error(), 26) {
+			fmt.Fprintln(txt, line)
+		}
+		txt.Color = colornames.Black
+	} else {
+		st := e.Stats
+		seconds := int(st.Duration + 0.5)
+		fmt.Fprintf(txt, "%d notes, from %s to %s\n", st.Notes, st.Low.Name, st.High.Name)
+		fmt.Fprintf(txt, "%d:%02d at %d bpm\n", seconds/60, seconds%60, e.TargetBPM())
+		fmt.Fprintf(txt, "Difficulty: %.1f\n", st.Difficulty)
+	}
+	key, signature := e.Key, e.Time
+	if key == "" {
+		key = "c"
+	}
+	if signature == "" {
+		signature = "4/4"
+	}
+	fmt.Fprintf(txt, "Key: %s, time: %s\n", key, signature)
+	if e.Transpose != 0 {
+		fmt.Fprintln(txt, transposeLabel(e.Transpose))
+	}
+	fmt.Fprintln(txt)
+	if h := e.History; h.Best > 0 && h.Difficulty != "" {
+		fmt.Fprintf(txt, "Best: %d on %s, grade %s\n", h.Best, h.Difficulty, h.Grade)
+	} else if h.Best > 0 { // from before difficulty was remembered
+		fmt.Fprintf(txt, "Best: %d, grade %s\n", h.Best, h.Grade)
+	} else {
+		fmt.Fprintln(txt, "No challenge score yet")
+	}
+	if e.History.LastPlayed.IsZero() {
+		fmt.Fprintln(txt, "Never played")
+	} else {
+		fmt.Fprintf(txt, "Last played %s\n", e.History.LastPlayed.Format("2 Jan 2006"))
+	}
+	renderTextBox(win, location, txt, scale, 0.6, 15)
+}
@@ -0,0 +1,46 @@
+package game
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+)
+
+// Frames per second of the exported video
+const VideoFPS = 30
+
+// ExportVideo plays the replay off-screen, and saves it to the directory as numbered PNG images, VideoFPS per second.
+// They could be joined into the video with
+// ffmpeg -framerate 30 -i dir/%05d.png video.mp4
+// Returns the number of frames saved.
+func ExportVideo(r *Replay, dir string, bounds pixel.Rect) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	win := ui.NewOffscreen(bounds)
+	input := &replayInput{frames: r.Frames}
+	session, err := setupReplay(r, input)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := 0.0 // time of the replay, in seconds
+	written := 0
+	for {
+		cursor := input.cursor
+		if session.Loop(win) != ui.Scene(session) {
+			break
+		}
+		for _, f := range input.frames[cursor:input.cursor] {
+			elapsed += f.DT
+		}
+		for ; float64(written) < elapsed*VideoFPS; written++ {
+			if err := win.SavePNG(filepath.Join(dir, fmt.Sprintf("%05d.png", written))); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,59 @@
+package gameplay
+
+import (
+	"math"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Follow mode moves notes in the tempo of the player.
+// Timeline speeds up when notes are started early, and slows down when they are started late,
+// or while player did not start the note that should be played already.
+type Follow struct {
+	Rate      float64 // speed of the timeline relative to the song tempo
+	target    float64 // speed to which timeline is smoothly going
+	elapsed   float64 // wall clock time since the start
+	lastOnset float64 // wall clock time when previous note of the song was started, negative if none
+	lastTime  float64 // and the time of that note in the song
+}
+
+func NewFollow() *Follow {
+	return &Follow{Rate: 1.0, target: 1.0, lastOnset: -1.0}
+}
+
+// Player started note of the song when timeline was at the given position
+func (f *Follow) onset(sn notes.SongNote, position float64) {
+	if f.lastOnset >= 0 && f.elapsed > f.lastOnset && sn.Time > f.lastTime {
+		tempo := (sn.Time - f.lastTime) / (f.elapsed - f.lastOnset) // seconds of song per second of playing
+		early := sn.Time - position                                 // negative when player is late
+		f.target = math.Max(config.FollowMinRate, math.Min(config.FollowMaxRate,
+			tempo*(1+config.FollowPhaseGain*early),
+		))
+	}
+	f.lastOnset = f.elapsed
+	f.lastTime = sn.Time
+}
+
+// Notes move in the tempo estimated from the starts of the notes played
+func (s *State) followUpdate(dt float64, note notes.Pitch) {
+	f := s.Follow
+	f.elapsed += dt
+	target := f.target
+	if s.waitingForOnset() {
+		target = math.Min(target, config.FollowWaitRate)
+	}
+	f.Rate += (target - f.Rate) * math.Min(1.0, dt*config.FollowResponse)
+	s.Duration += dt * f.Rate
+	s.playAlong(note)
+}
+
+// Whether there is a note in the song that should be started already, but was not
+func (s *State) waitingForOnset() bool {
+	for i := s.missCursor; i < len(s.Song) && s.Song[i].Time < s.Duration; i++ {
+		if !s.onsetJudged[i] && s.Song[i].Pitch.Name != "p" && s.Song[i].Time >= 0 {
+			return true
+		}
+	}
+	return false
+}
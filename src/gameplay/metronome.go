@@ -0,0 +1,39 @@
+package gameplay
+
+import "math"
+
+// Metronome counts beats of the song to click on, and accents the beats of the count-in before the first note.
+// Clicks are played by the game.
+type Metronome struct {
+	BPM       int
+	FirstNote float64 // time of the first note in the song, beats are counted from it
+	lastBeat  int     // number of the last beat clicked
+}
+
+func NewMetronome(bpm int, firstNote float64) *Metronome {
+	m := &Metronome{}
+	m.Reset(bpm, firstNote)
+	return m
+}
+
+// Start counting beats again, for example when song is started over
+func (m *Metronome) Reset(bpm int, firstNote float64) {
+	m.BPM = bpm
+	m.FirstNote = firstNote
+	// first beat to click is the one at the start of the song or right after it
+	m.lastBeat = int(math.Ceil(-firstNote/m.beatDuration())) - 1
+}
+
+func (m *Metronome) beatDuration() float64 {
+	return 60.0 / float64(m.BPM)
+}
+
+// Tick returns whether song time t reached the next beat, and whether the beat is accented
+func (m *Metronome) Tick(t float64) (click, accent bool) {
+	beat := int(math.Floor((t - m.FirstNote) / m.beatDuration()))
+	if beat <= m.lastBeat {
+		return false, false
+	}
+	m.lastBeat = beat
+	return true, beat < 0
+}
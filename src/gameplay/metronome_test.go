@@ -0,0 +1,70 @@
+package gameplay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type click struct {
+	Time   float64
+	Accent bool
+}
+
+// Song time of a pass starts from 0, as in practice, where the metronome is reset with the new tempo
+type pass struct {
+	BPM       int
+	FirstNote float64
+	Seconds   float64
+}
+
+func TestMetronome(t *testing.T) {
+	tests := []struct {
+		name   string
+		passes []pass
+		clicks [][]click // of every pass
+	}{
+		{
+			name:   "no count-in",
+			passes: []pass{{bpm, 0, 2}},
+			clicks: [][]click{{{0, false}, {0.625, false}, {1.25, false}, {1.875, false}}},
+		},
+		{
+			name:   "count-in is accented",
+			passes: []pass{{bpm, 1.25, 2.5}},
+			clicks: [][]click{{{0, true}, {0.625, true}, {1.25, false}, {1.875, false}}},
+		},
+		{
+			name:   "count-in starts on the beat after the start",
+			passes: []pass{{bpm, 1, 2}},
+			clicks: [][]click{{{0.375, true}, {1, false}, {1.625, false}}},
+		},
+		{
+			name:   "tempo change",
+			passes: []pass{{bpm, 1.25, 1.5}, {120, 1, 2}},
+			clicks: [][]click{
+				{{0, true}, {0.625, true}, {1.25, false}},
+				{{0, true}, {0.5, true}, {1, false}, {1.5, false}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m *Metronome
+			for i, p := range tt.passes {
+				if m == nil {
+					m = NewMetronome(p.BPM, p.FirstNote)
+				} else {
+					m.Reset(p.BPM, p.FirstNote)
+				}
+				var clicks []click
+				for time := 0.0; time < p.Seconds; time += dt {
+					if ok, accent := m.Tick(time); ok {
+						clicks = append(clicks, click{time, accent})
+					}
+				}
+				assert.Equal(t, tt.clicks[i], clicks, "pass %d", i)
+			}
+		})
+	}
+}
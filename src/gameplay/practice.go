@@ -0,0 +1,65 @@
+package gameplay
+
+import (
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Practice mode plays a section of the song over and over,
+// increasing tempo after each pass without mistakes, until target tempo is reached.
+type Practice struct {
+	From, To   int  // indexes of the first and last note of the section
+	CountIn    bool // give one bar before the first note
+	TargetBPM  int
+	Passes     int              // passes without mistakes so far
+	notes      []notes.SongNote // notes of the section, for the tempo where whole note lasts 1 second
+	passBreaks int              // combo breaks before the current pass
+}
+
+// song is parsed for the tempo where whole note lasts 1 second
+func NewPractice(song []notes.SongNote, from, to int, countIn bool, targetBPM int) *Practice {
+	return &Practice{
+		From:      from,
+		To:        to,
+		CountIn:   countIn,
+		TargetBPM: targetBPM,
+		notes:     song[from : to+1],
+	}
+}
+
+// Notes of the section in given tempo, moved to the start of the session
+func (p Practice) Section(bpm int) []notes.SongNote {
+	fullDuration := 240.0 / float64(bpm)
+	lead := config.TimeBeforeFirstNote
+	if p.CountIn {
+		lead = fullDuration // one bar of four beats
+	}
+	section := make([]notes.SongNote, len(p.notes))
+	for i, n := range p.notes {
+		n.Time = lead + (n.Time-p.notes[0].Time)*fullDuration
+		n.Duration *= fullDuration
+		section[i] = n
+	}
+	return section
+}
+
+// Called when section is played to the end. Starts next pass, or returns false when practice is over.
+func (s *State) nextPass() bool {
+	s.judgeRelease()
+	s.judgeMissed()
+	p := s.Practice
+	if s.Scorer.Breaks == p.passBreaks { // no mistakes
+		p.Passes++
+		if s.BPM >= p.TargetBPM {
+			return false
+		}
+		s.BPM += config.PracticeTempoStep
+		if s.BPM > p.TargetBPM {
+			s.BPM = p.TargetBPM
+		}
+	}
+	p.passBreaks = s.Scorer.Breaks
+	s.SetSong(p.Section(s.BPM))
+	s.heldOver = s.Playing // last note of the previous pass
+	return true
+}
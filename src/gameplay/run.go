@@ -0,0 +1,35 @@
+package gameplay
+
+import (
+	"math"
+
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Hold is a pitch played for some time, to script the player
+type Hold struct {
+	Pitch    notes.Pitch // notes.Pause for silence
+	Duration float64     // in seconds
+}
+
+// Script turns holds into frames of dt seconds each
+func Script(dt float64, holds ...Hold) []Frame {
+	var frames []Frame
+	for _, h := range holds {
+		for i := 0; i < int(math.Round(h.Duration/dt)); i++ {
+			frames = append(frames, Frame{DT: dt, Pitch: h.Pitch.Frequency})
+		}
+	}
+	return frames
+}
+
+// Run updates the state with frames, until they end or session is over.
+// Returns false when session is over.
+func Run(s *State, frames []Frame) bool {
+	for _, f := range frames {
+		if !s.Update(f) {
+			return false
+		}
+	}
+	return true
+}
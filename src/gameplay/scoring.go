@@ -0,0 +1,101 @@
+package gameplay
+
+import (
+	"math"
+
+	"github.com/bunyk/fasolasi/src/config"
+)
+
+// How close to the right moment note was started or stopped
+type Judgement int
+
+const (
+	Miss Judgement = iota
+	Good
+	Great
+	Perfect
+)
+
+var judgementNames = []string{"Miss", "Good", "Great", "Perfect"}
+var judgementPoints = []float64{0, 1, 2, 3}
+
+func (j Judgement) String() string {
+	return judgementNames[j]
+}
+
+// Scorer counts points for the challenge mode.
+// Every note gives points twice: when it is started, and when it is stopped.
+// Hits in a row make a combo, which multiplies points.
+type Scorer struct {
+	Difficulty config.Difficulty
+	Points     float64
+	Combo      int
+	MaxCombo   int
+	Breaks     int              // how many times combo was broken
+	Counts     [Perfect + 1]int // how many times each judgement was given
+}
+
+// Judge how good is the hit that happened offset seconds away from the right moment
+func (sc Scorer) Judge(offset float64) Judgement {
+	offset = math.Abs(offset)
+	switch {
+	case offset <= sc.Difficulty.Perfect:
+		return Perfect
+	case offset <= sc.Difficulty.Great:
+		return Great
+	case offset <= sc.Difficulty.Good:
+		return Good
+	}
+	return Miss
+}
+
+func (sc Scorer) Multiplier() int {
+	m := 1 + sc.Combo/config.ComboStep
+	if m > config.MaxMultiplier {
+		return config.MaxMultiplier
+	}
+	return m
+}
+
+// Add judgement to the score
+func (sc *Scorer) Add(j Judgement) {
+	sc.Counts[j]++
+	if j == Miss {
+		sc.Break()
+		return
+	}
+	sc.Points += judgementPoints[j] * float64(sc.Multiplier())
+	sc.Combo++
+	if sc.Combo > sc.MaxCombo {
+		sc.MaxCombo = sc.Combo
+	}
+}
+
+// Break the combo, for example because of the wrong note
+func (sc *Scorer) Break() {
+	sc.Combo = 0
+	sc.Breaks++
+}
+
+// Score of the player who makes no mistakes in the song with given number of hits
+func MaxScore(hits int) float64 {
+	var sc Scorer
+	for i := 0; i < hits; i++ {
+		sc.Add(Perfect)
+	}
+	return sc.Points
+}
+
+// Grade of the score relative to the maximal score
+func (sc Scorer) Grade(max float64) string {
+	if max <= 0 {
+		return "-"
+	}
+	ratio := sc.Points / max
+	for i, grade := range []string{"S", "A", "B"} {
+		if ratio >= sc.Difficulty.Grades[i] {
+			return grade
+		}
+	}
+	return "C"
+}
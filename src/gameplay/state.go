@@ -0,0 +1,350 @@
+// Package gameplay is the game session without window, sound and clock.
+// It is driven by frames of input, so it could be tested, or run from a replay.
+package gameplay
+
+import (
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// State of the game session
+type State struct {
+	Song         []notes.SongNote
+	Mode         string
+	BPM          int
+	Difficulty   config.Difficulty
+	Played       []PlayedNote
+	Playing      notes.Pitch // note played now
+	Score        float64
+	PlayToStart  float64     // if this is < 1.0 game is not started yet
+	heldOver     notes.Pitch // held since before the song started, like C played to start, it is not a note of the song
+	Duration     float64     // session duration, progress of song in seconds
+	SongDuration float64     // Duration of the song in seconds
+	SongCursor   int         // number of passsed notes in song
+	Round        int         // how many times song was started, practice mode repeats it
+	Paused       bool
+	updateMode   func(dt float64, note notes.Pitch)
+
+	// Challenge scoring
+	Scorer        Scorer
+	maxScore      float64
+	onsetJudged   []bool    // for every note of the song - whether its start was already judged
+	holding       int       // index of the song note being played now, -1 if none
+	missCursor    int       // notes before this one were checked for misses
+	LastJudgement Judgement // to show it to the player
+
+	training TrainingStats
+	Practice *Practice // only for practice mode
+	Follow   *Follow   // only for follow mode
+}
+
+type PlayedNote struct {
+	notes.SongNote
+	Correct bool
+}
+
+// Frame is the input of the session during one frame
+type Frame struct {
+	DT    float64 `yaml:"dt"`              // seconds since the previous frame
+	Pitch float64 `yaml:"pitch"`           // frequency heard, in Hz, negative for silence
+	Pause bool    `yaml:"pause,omitempty"` // pause was toggled
+	Stop  bool    `yaml:"stop,omitempty"`  // session was stopped before the end
+}
+
+func New(mode string, bpm int, difficulty config.Difficulty, song []notes.SongNote) *State {
+	s := &State{
+		Played:     make([]PlayedNote, 0, 100),
+		Mode:       mode,
+		BPM:        bpm,
+		Difficulty: difficulty,
+	}
+	switch mode {
+	case "training":
+		s.updateMode = s.trainingUpdate
+	case "follow":
+		s.Follow = NewFollow()
+		s.updateMode = s.followUpdate
+	default:
+		s.updateMode = s.challengeUpdate
+	}
+	s.Scorer.Difficulty = s.Difficulty
+	s.SetSong(song)
+	return s
+}
+
+// Started is true when player played C for one second to start the session
+func (s *State) Started() bool {
+	return s.PlayToStart >= 1.0
+}
+
+// Update the session with one frame of input. Returns false when session is over.
+func (s *State) Update(f Frame) bool {
+	if f.Stop {
+		return false
+	}
+	if f.Pause && s.Started() {
+		s.Paused = !s.Paused
+	}
+	s.Playing, _ = notes.GuessNote(f.Pitch)
+	if !s.Started() {
+		if s.Playing == notes.C { // Play c for one second to start
+			s.PlayToStart += f.DT
+		}
+		if s.Started() {
+			s.heldOver = notes.C
+		}
+		return true
+	}
+	if s.Playing != s.heldOver {
+		s.heldOver = notes.Pause
+	}
+	if s.Paused {
+		return true
+	}
+	if s.Finished() && (s.Practice == nil || !s.nextPass()) {
+		return false
+	}
+	s.updateMode(f.DT, s.Playing)
+	return true
+}
+
+// Set notes to play and reset the progress
+func (s *State) SetSong(song []notes.SongNote) {
+	s.Song = append([]notes.SongNote{{Duration: 1.0, Time: -1.0, Pitch: notes.C}}, song...)
+	s.Played = s.Played[:0]
+	s.Duration = 0
+	s.SongCursor = 0
+	s.onsetJudged = make([]bool, len(s.Song))
+	s.holding = -1
+	s.missCursor = 0
+	hits := 0
+	for _, n := range song {
+		if n.Pitch.Name != "p" {
+			hits += 2 // start and end
+		}
+	}
+	s.maxScore = MaxScore(hits)
+	s.training = NewTrainingStats(len(s.Song))
+	s.SongDuration = song[len(song)-1].End()
+	s.Round++
+}
+
+func (s State) Finished() bool {
+	return s.SongCursor >= len(s.Song)
+}
+
+func (s *State) moveSongCursor() {
+	// skip played notes
+	for !s.Finished() && s.Song[s.SongCursor].End() < s.Duration {
+		s.SongCursor += 1
+	}
+}
+
+func (s *State) nextNote() notes.SongNote {
+	s.moveSongCursor()
+	if s.Finished() {
+		return notes.SongNote{}
+	}
+	return s.Song[s.SongCursor]
+}
+
+func (s *State) currentNote() notes.Pitch {
+	s.moveSongCursor()
+
+	// no notes to play left
+	if s.Finished() {
+		return notes.Pause
+	}
+	nn := s.Song[s.SongCursor]
+
+	// should be playing some note right now
+	if nn.Time < s.Duration {
+		return nn.Pitch
+	}
+	// otherwise - not yet playing anything
+	return notes.Pause
+}
+
+// Notes don't stop, and you need to hit correct ones in time
+func (s *State) challengeUpdate(dt float64, note notes.Pitch) {
+	s.Duration += dt
+	s.playAlong(note)
+}
+
+// Record played note and score it, while timeline is moving
+func (s *State) playAlong(note notes.Pitch) {
+	playingCorrectly := note == s.currentNote()
+	if note.Name != "p" {
+		if len(s.Played) == 0 || s.Played[len(s.Played)-1].End() > 0 { // no note currently playing
+			s.judgeOnset(note)
+			s.Played = append(s.Played, PlayedNote{
+				SongNote: notes.SongNote{ // create new note
+					Time:     s.Duration,
+					Pitch:    note,
+					Duration: -1.0,
+				},
+				Correct: playingCorrectly,
+			})
+		} else if s.Played[len(s.Played)-1].Pitch != note || s.Played[len(s.Played)-1].Correct != playingCorrectly { // note changed
+			s.Played[len(s.Played)-1].Duration = s.Duration - s.Played[len(s.Played)-1].Time // end current one
+			// new pitch, not just the same note that became (in)correct
+			if s.Played[len(s.Played)-1].Pitch != note {
+				s.judgeRelease()
+				s.judgeOnset(note)
+			}
+			s.Played = append(s.Played, PlayedNote{
+				SongNote: notes.SongNote{ // create new note
+					Time:     s.Duration,
+					Pitch:    note,
+					Duration: -1.0,
+				},
+				Correct: playingCorrectly,
+			})
+		}
+	} else { // no note
+		if len(s.Played) > 0 && s.Played[len(s.Played)-1].End() < 0 { // there is a note still playing
+			s.Played[len(s.Played)-1].Duration = s.Duration - s.Played[len(s.Played)-1].Time // end it
+			s.judgeRelease()
+		}
+	}
+	s.judgeMissed()
+	s.Score = s.Scorer.Points
+
+	if len(s.Played) > 2 && s.Played[0].End() < s.Duration-config.TimeLinePosition/config.NoteSPS { // note not visible
+		s.Played = s.Played[1:] // remove
+	}
+}
+
+// Player started to play note, find which note of the song it should be
+func (s *State) judgeOnset(note notes.Pitch) {
+	if note == s.heldOver {
+		return
+	}
+	for i := s.missCursor; i < len(s.Song); i++ {
+		sn := s.Song[i]
+		if sn.Time-s.Duration > s.Difficulty.Good { // this and next notes are too far in the future
+			break
+		}
+		if s.onsetJudged[i] || sn.Pitch != note || sn.Time < 0 {
+			continue
+		}
+		j := s.Scorer.Judge(sn.Time - s.Duration)
+		if j == Miss {
+			continue
+		}
+		s.onsetJudged[i] = true
+		s.holding = i
+		s.judge(j)
+		if s.Follow != nil {
+			s.Follow.onset(sn, s.Duration)
+		}
+		return
+	}
+	s.Scorer.Break() // this note is not in the song
+}
+
+// Player stopped to play note, check if that was the right time
+func (s *State) judgeRelease() {
+	if s.holding < 0 {
+		return
+	}
+	s.judge(s.Scorer.Judge(s.Song[s.holding].End() - s.Duration))
+	s.holding = -1
+}
+
+// Count notes that should have been started already but were not
+func (s *State) judgeMissed() {
+	for s.missCursor < len(s.Song) && s.Song[s.missCursor].Time+s.Difficulty.Good < s.Duration {
+		sn := s.Song[s.missCursor]
+		if !s.onsetJudged[s.missCursor] && sn.Pitch.Name != "p" && sn.Time >= 0 {
+			s.onsetJudged[s.missCursor] = true
+			s.judge(Miss) // start missed
+			s.judge(Miss) // and so the end also
+		}
+		s.missCursor++
+	}
+}
+
+func (s *State) judge(j Judgement) {
+	s.Scorer.Add(j)
+	s.LastJudgement = j
+}
+
+// Notes move only while you play correct note, to progress - play all the notes in correct orders.
+// Obeying durations is optional.
+func (s *State) trainingUpdate(dt float64, note notes.Pitch) {
+	s.training.Elapsed += dt
+	newNote := note.Name != s.training.lastInput
+	s.training.lastInput = note.Name
+
+	nn := s.nextNote()
+	if s.Finished() {
+		return
+	}
+	if note.Name != "p" {
+		if len(s.Played) > 0 { // we were already playing some note
+			if s.Played[0].Pitch == note { // still playing it
+				s.Duration += dt
+				if s.Duration > s.Played[0].End() { // Should have stopped already
+					s.Duration = s.Played[0].End()
+					s.Played[0].Correct = false
+				}
+				return // and that's it for continuing playing note
+			}
+		}
+		if note.Name == nn.Pitch.Name { // start playing current note
+			s.Played = []PlayedNote{{
+				SongNote: nn,
+				Correct:  true,
+			}}
+			s.Score += s.training.NoteScore(s.SongCursor, nn.Duration)
+			s.Duration = nn.Time
+			s.SongCursor += 1 // Prepare for next note
+			return
+		}
+		if newNote {
+			s.training.Wrong[s.SongCursor]++
+		}
+	} else { // no note, probably stopped playing
+		s.Played = nil
+		s.Duration = nn.Time // Move timeline to next note
+		if nn.Pitch.Name == "p" {
+			s.SongCursor += 1
+			return
+		}
+	}
+	s.training.Waits[s.SongCursor] += dt
+}
+
+func (s State) RoundedScore() int {
+	return int(s.Score * 100)
+}
+
+// Result of the session
+type Result struct {
+	Score          int
+	Grade          string // only for challenge
+	MaxCombo       int
+	RecommendedBPM int // only for training
+	Passes         int // only for practice
+}
+
+// Finish the session: judge note that is still played, and notes that were not
+func (s *State) Finish() Result {
+	if s.Mode == "training" {
+		return Result{
+			Score:          s.RoundedScore(),
+			RecommendedBPM: s.training.RecommendedBPM(s.BPM, s.SongDuration),
+		}
+	}
+	s.judgeRelease()
+	s.judgeMissed()
+	s.Score = s.Scorer.Points
+	r := Result{Score: s.RoundedScore(), MaxCombo: s.Scorer.MaxCombo}
+	if s.Practice != nil {
+		r.Passes = s.Practice.Passes
+	} else {
+		r.Grade = s.Scorer.Grade(s.maxScore)
+	}
+	return r
+}
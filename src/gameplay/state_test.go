@@ -0,0 +1,266 @@
+package gameplay
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/stretchr/testify/assert"
+)
+
+// Frames are power of 2 fractions of the second, so time adds up exactly.
+// At 96 bpm durations of notes and breaths between them are also multiples of it.
+const dt = 1.0 / 64
+const bpm = 96
+
+var normal = config.Difficulties[1]
+
+func parse(t *testing.T, text string, bpm int) []notes.SongNote {
+	song, err := config.Song{Notes: text}.ParseNotes(240.0 / float64(bpm))
+	assert.NoError(t, err)
+	return song
+}
+
+// Play C for one second to start the session
+var start = Hold{notes.C, 1.0}
+
+// Holds of the player who plays every note of the song exactly in time
+func perfectPlayer(song []notes.SongNote) []Hold {
+	holds := []Hold{start}
+	// Timeline moves on the first frame after the start, so notes are started a frame after their time.
+	// Exactly at its time note is not yet the one to play.
+	time := 0.0
+	for _, n := range song {
+		holds = append(holds, Hold{notes.Pause, n.Time - time}, Hold{n.Pitch, n.Duration})
+		time = n.End()
+	}
+	return append(holds, Hold{notes.Pause, 1.0})
+}
+
+func TestChallengePerfect(t *testing.T) {
+	song := parse(t, "c d e2", bpm)
+	s := New("challenge", bpm, normal, song)
+	assert.False(t, Run(s, Script(dt, perfectPlayer(song)...)))
+
+	r := s.Finish()
+	assert.Equal(t, "S", r.Grade)
+	assert.Equal(t, 6, r.MaxCombo)
+	assert.Equal(t, 6, s.Scorer.Counts[Perfect])
+	assert.Equal(t, int(MaxScore(6)*100), r.Score)
+	assert.Len(t, s.Played, 3)
+	for _, p := range s.Played {
+		assert.True(t, p.Correct)
+	}
+}
+
+func TestChallengeSilence(t *testing.T) {
+	song := parse(t, "c d e2", bpm)
+	s := New("challenge", bpm, normal, song)
+	assert.False(t, Run(s, Script(dt, start, Hold{notes.Pause, 10})))
+
+	r := s.Finish()
+	assert.Equal(t, "C", r.Grade)
+	assert.Equal(t, 0, r.Score)
+	assert.Equal(t, 6, s.Scorer.Counts[Miss])
+	assert.Empty(t, s.Played)
+}
+
+func TestChallengeWrongNote(t *testing.T) {
+	song := parse(t, "c d e2", bpm)
+	holds := perfectPlayer(song)
+	holds[4].Pitch = notes.PitchByName["f"] // instead of d
+	s := New("challenge", bpm, normal, song)
+	Run(s, Script(dt, holds...))
+
+	r := s.Finish()
+	assert.Equal(t, 4, s.Scorer.Counts[Perfect])
+	assert.Equal(t, 2, s.Scorer.Counts[Miss])
+	assert.Equal(t, 2, r.MaxCombo)
+}
+
+func TestNotStartedWithoutC(t *testing.T) {
+	song := parse(t, "c d e2", bpm)
+	s := New("challenge", bpm, normal, song)
+	assert.True(t, Run(s, Script(dt, Hold{notes.PitchByName["d"], 5})))
+	assert.False(t, s.Started())
+	assert.Equal(t, 0.0, s.Duration)
+}
+
+func TestPause(t *testing.T) {
+	song := parse(t, "c d e2", bpm)
+	s := New("challenge", bpm, normal, song)
+	Run(s, Script(dt, start, Hold{notes.Pause, 0.5}))
+	duration := s.Duration
+
+	Run(s, []Frame{{DT: dt, Pitch: -1, Pause: true}})
+	Run(s, Script(dt, Hold{notes.Pause, 3}))
+	assert.Equal(t, duration, s.Duration)
+
+	Run(s, []Frame{{DT: dt, Pitch: -1, Pause: true}})
+	assert.Equal(t, duration+dt, s.Duration)
+}
+
+func TestTraining(t *testing.T) {
+	song := parse(t, "c d e2", bpm)
+	// C played to start the session is the first note, and it is scored too
+	holds := []Hold{start, {notes.C, 0.25}, {notes.Pause, 0.125}}
+	for _, n := range song {
+		holds = append(holds, Hold{n.Pitch, n.Duration}, Hold{notes.Pause, 0.125})
+	}
+	s := New("training", bpm, normal, song)
+	assert.False(t, Run(s, Script(dt, append(holds, Hold{notes.Pause, 1})...)))
+	assert.InDelta(t, 4.0, s.Score, 1e-9)
+	assert.Equal(t, bpm/5*5, s.Finish().RecommendedBPM)
+
+	// waiting and wrong notes make score lower
+	s = New("training", bpm, normal, song)
+	Run(s, Script(dt, holds[:5]...))
+	Run(s, Script(dt, Hold{notes.Pause, 1}, Hold{notes.PitchByName["f"], 0.25}, Hold{notes.Pause, 0.125}))
+	Run(s, Script(dt, holds[5:]...))
+	assert.True(t, s.Score < 3.5, "score %f", s.Score)
+	assert.True(t, s.Score > 3.0, "score %f", s.Score)
+	assert.True(t, s.Finish().RecommendedBPM < bpm)
+}
+
+func TestPracticeSpeedsUp(t *testing.T) {
+	whole := parse(t, "c d e2", 240) // whole note lasts 1 second
+	p := NewPractice(whole, 0, 1, false, 130)
+	s := New("practice", 120, normal, p.Section(120))
+	s.Practice = p
+	section := s.Song[1:]
+	assert.Len(t, section, 2)
+
+	Run(s, Script(dt, perfectPlayer(section)...))
+	assert.Equal(t, 1, p.Passes)
+	assert.Equal(t, 120+config.PracticeTempoStep, s.BPM)
+	assert.Equal(t, 2, s.Round)
+}
+
+// Plays the holds, or the notes of the current pass in time, until the next pass starts, or practice is over
+func playPass(s *State, holds ...Hold) bool {
+	round := s.Round
+	if holds == nil {
+		holds = perfectPlayer(s.Song[1:])[1:]
+	}
+	for _, f := range Script(dt, holds...) {
+		if !s.Update(f) {
+			return false
+		}
+		if s.Round != round {
+			return true
+		}
+	}
+	return true
+}
+
+func TestPracticePasses(t *testing.T) {
+	whole := parse(t, "c d e2", 240)
+	p := NewPractice(whole, 0, 2, false, 110)
+	s := New("practice", 100, normal, p.Section(100))
+	s.Practice = p
+
+	// C to start is held a bit longer than needed, and it is not a mistake
+	holds := perfectPlayer(s.Song[1:])
+	holds[0].Duration += 0.25
+	holds[1].Duration -= 0.25
+	assert.True(t, playPass(s, holds...))
+	assert.Equal(t, 1, p.Passes)
+	assert.Equal(t, 100+config.PracticeTempoStep, s.BPM)
+
+	// last note of the pass is still held when the next one starts, and it is not a mistake too
+	assert.True(t, playPass(s))
+	assert.Equal(t, 2, p.Passes)
+	assert.Equal(t, 110, s.BPM)
+	assert.False(t, playPass(s), "practice is over at the target tempo")
+	assert.Equal(t, 0, s.Scorer.Breaks)
+}
+
+// Holds of the player who plays the song in the tempo of the given speed, from the time of its first note
+func tempoPlayer(song []notes.SongNote, speed float64) []Hold {
+	holds := []Hold{start, {notes.Pause, song[0].Time}}
+	for i, n := range song {
+		length := n.Duration / speed
+		if i+1 < len(song) {
+			length = (song[i+1].Time - n.Time) / speed
+		}
+		holds = append(holds, Hold{n.Pitch, length * 0.8}, Hold{notes.Pause, length * 0.2})
+	}
+	return append(holds, Hold{notes.Pause, 1.0})
+}
+
+// Runs frames, and returns the slowest and fastest rate of the timeline
+func runFollow(s *State, frames []Frame) (slowest, fastest float64) {
+	slowest, fastest = s.Follow.Rate, s.Follow.Rate
+	for _, f := range frames {
+		if !s.Update(f) {
+			break
+		}
+		slowest, fastest = math.Min(slowest, s.Follow.Rate), math.Max(fastest, s.Follow.Rate)
+	}
+	return slowest, fastest
+}
+
+var scale = "c d e f g a b c' b a g f e d c"
+
+func TestFollowTempo(t *testing.T) {
+	song := parse(t, scale, bpm)
+	for _, speed := range []float64{0.8, 1, 1.3} {
+		s := New("follow", bpm, normal, song)
+		slowest, fastest := runFollow(s, Script(dt, tempoPlayer(song, speed)...))
+		assert.True(t, s.Finished())
+		assert.True(t, slowest >= config.FollowMinRate && fastest <= config.FollowMaxRate, "rate from %f to %f", slowest, fastest)
+		assert.InDelta(t, speed, s.Follow.Rate, 0.05, "speed %f", speed)
+		assert.Equal(t, 0, s.Scorer.Counts[Miss], "speed %f", speed)
+		assert.Equal(t, 0, s.Scorer.Breaks, "speed %f", speed)
+	}
+}
+
+func TestFollowPhase(t *testing.T) {
+	song := parse(t, scale, bpm)
+	// player in the tempo of the song, but starting every note earlier or later than the timeline
+	for _, shift := range []float64{-0.15, 0.15} {
+		holds := tempoPlayer(song, 1)
+		holds[1].Duration += shift
+		s := New("follow", bpm, normal, song)
+		slowest, fastest := runFollow(s, Script(dt, holds...))
+		if shift < 0 { // early, so timeline runs ahead
+			assert.True(t, fastest > 1.03, "fastest %f", fastest)
+		} else { // late, so timeline waits
+			assert.True(t, slowest < 0.97, "slowest %f", slowest)
+		}
+		assert.InDelta(t, 1, s.Follow.Rate, 0.05, "timeline caught up, shift %f", shift)
+		assert.Equal(t, 0, s.Scorer.Counts[Miss], "shift %f", shift)
+	}
+}
+
+func TestFollowWaits(t *testing.T) {
+	song := parse(t, scale, bpm)
+	holds := tempoPlayer(song, 1)
+	s := New("follow", bpm, normal, song)
+	Run(s, Script(dt, holds[:8]...)) // start and first three notes
+	position := s.Duration
+	// timeline slows down while the next note is not started, until it is missed
+	slowest, _ := runFollow(s, Script(dt, Hold{notes.Pause, 2}))
+	assert.True(t, slowest < 0.9, "slowest %f", slowest)
+	assert.True(t, slowest >= config.FollowWaitRate, "slowest %f", slowest)
+	assert.True(t, s.Duration-position < 2, "timeline moved %f in 2 seconds", s.Duration-position)
+	assert.True(t, s.Scorer.Counts[Miss] > 0)
+}
+
+func TestFollowClamps(t *testing.T) {
+	second := func(tempo, early float64) float64 { // target rate after the second note, started at the tempo
+		f := NewFollow()
+		f.onset(notes.SongNote{Time: 1}, 1)
+		f.elapsed = 1 / tempo
+		f.onset(notes.SongNote{Time: 2}, 2-early)
+		return f.target
+	}
+	assert.InDelta(t, 1.2, second(1.2, 0), 1e-9)
+	assert.InDelta(t, 1.2*(1+config.FollowPhaseGain*0.1), second(1.2, 0.1), 1e-9)
+	assert.InDelta(t, 0.8*(1-config.FollowPhaseGain*0.1), second(0.8, -0.1), 1e-9)
+	assert.Equal(t, config.FollowMaxRate, second(5, 0))
+	assert.Equal(t, config.FollowMaxRate, second(1.4, 1))
+	assert.Equal(t, config.FollowMinRate, second(0.1, 0))
+	assert.Equal(t, config.FollowMinRate, second(0.6, -1))
+}
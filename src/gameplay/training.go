@@ -0,0 +1,50 @@
+package gameplay
+
+import (
+	"math"
+
+	"github.com/bunyk/fasolasi/src/config"
+)
+
+// Statistics of the training mode. Used to score it and to suggest tempo for the challenge.
+type TrainingStats struct {
+	Elapsed   float64   // wall clock time spent on the song, in seconds
+	Waits     []float64 // for every note of the song: how long player waited before starting it
+	Wrong     []int     // and how many wrong notes were started meanwhile
+	lastInput string    // name of the note played on the previous update, to detect new notes
+}
+
+func NewTrainingStats(songLength int) TrainingStats {
+	return TrainingStats{
+		Waits: make([]float64, songLength),
+		Wrong: make([]int, songLength),
+	}
+}
+
+// Extra time spent waiting for note to be started, counting each wrong attempt as a penalty
+func (ts TrainingStats) extraTime(i int) float64 {
+	return math.Max(0, ts.Waits[i]-config.TrainingReactionTime) + float64(ts.Wrong[i])*config.WrongNotePenalty
+}
+
+// Score for the note is 1.0 when it is started right away, and is inversely proportional to extra time otherwise
+func (ts TrainingStats) NoteScore(i int, duration float64) float64 {
+	return duration / (duration + ts.extraTime(i))
+}
+
+// Tempo at which player would probably play the song without stopping.
+// bpm is the tempo of the training, songDuration - how long it would take to play the song in that tempo.
+func (ts TrainingStats) RecommendedBPM(bpm int, songDuration float64) int {
+	wrong := 0
+	for _, w := range ts.Wrong {
+		wrong += w
+	}
+	spent := math.Max(ts.Elapsed, songDuration) + float64(wrong)*config.WrongNotePenalty
+	if spent <= 0 {
+		return bpm
+	}
+	recommended := int(float64(bpm)*songDuration/spent) / 5 * 5 // round down to a multiple of 5
+	if recommended < config.MinBPM {
+		return config.MinBPM
+	}
+	return recommended
+}
@@ -0,0 +1,204 @@
+// Package highway draws the staff with notes moving along it.
+// It only needs ui.Canvas, so could draw off-screen.
+package highway
+
+import (
+	"image/color"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/gameplay"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+// Staff is where the notes are drawn on the screen
+type Staff struct {
+	Bottom float64 // y of the bottom line
+	Space  float64 // between the lines
+	Fifths int     // sharps in the key signature, or flats when negative, to name the notes
+}
+
+// Staff with big notes, for the piano roll
+func PianoRoll(bounds pixel.Rect) Staff {
+	return Staff{Bottom: bounds.H()/2 - config.NoteRadius*4, Space: config.NoteRadius * 2}
+}
+
+// Smaller staff, for the sheet music
+func SheetMusic(bounds pixel.Rect) Staff {
+	space := config.NoteRadius * 0.75
+	return Staff{Bottom: bounds.H()/2 - space*2, Space: space}
+}
+
+// Y of the note center
+func (st Staff) Y(p notes.Pitch) float64 {
+	return st.Bottom + p.Bottom*st.Space
+}
+
+// X of the note played at the time, when it is currentTime now
+func TimeX(width, time, currentTime float64) float64 {
+	return width * (config.TimeLinePosition - (currentTime-time)*config.NoteSPS)
+}
+
+// Notes of the song, and notes played over them in color
+func Notes(win ui.Canvas, st Staff, song []notes.SongNote, played []gameplay.PlayedNote, time float64) {
+	width := win.Bounds().W()
+	for _, note := range song {
+		renderNote(win, st, time, width, false, note)
+	}
+	for _, note := range played {
+		renderNote(win, st, time, width, note.Correct, note.SongNote)
+	}
+}
+
+var rainbow = []color.Color{
+	colornames.Red,
+	colornames.Orange,
+	colornames.Yellow,
+	colornames.Green,
+	colornames.Blue,
+	colornames.Violet,
+}
+
+func renderNote(win ui.Canvas, st Staff, time, width float64, colorful bool, note notes.SongNote) {
+	if note.Pitch.Name == "p" {
+		return
+	}
+	end := note.End()
+	if end < 0.0 {
+		end = time
+	}
+	endX := TimeX(width, end, time)
+	if endX < 0 { // invisible already
+		return
+	}
+	startX := TimeX(width, note.Time, time)
+	if startX > width { // still invisible
+		return
+	}
+	ycenter := st.Y(note.Pitch)
+	radius := st.Space / 2
+
+	imd := imdraw.New(nil)
+	imd.EndShape = imdraw.SharpEndShape
+	if note.Pitch.HasAdditionalLine() {
+		imd.Color = colornames.Black
+		imd.Push(
+			pixel.V(startX-radius*2, ycenter),
+			pixel.V(endX+radius*2, ycenter),
+		)
+		imd.Line(1)
+	}
+	border := 0.0
+	var textColor color.Color = colornames.White
+	if colorful {
+		imd.Color = rainbow[(10+int(note.Pitch.Bottom*4))%len(rainbow)]
+		textColor = oppositeColor(imd.Color)
+	} else {
+		if !note.Pitch.IsHalf { // white key
+			imd.Color = colornames.White
+			textColor = colornames.Black
+			imd.Push(
+				pixel.V(startX+1, ycenter-config.WhiteNoteWidth*radius+1),
+				pixel.V(endX-1, ycenter+config.WhiteNoteWidth*radius-1),
+			)
+			imd.Rectangle(0)
+			border = 2.0
+		}
+		imd.Color = colornames.Black
+	}
+
+	height := config.WhiteNoteWidth
+	if note.Pitch.IsHalf {
+		height = config.BlackNoteWidth
+	}
+	corner1 := pixel.V(startX+1, ycenter-height*radius+1)
+	corner2 := pixel.V(endX-1, ycenter+height*radius-1)
+	imd.Push(corner1, corner2)
+	imd.Rectangle(border)
+	imd.Draw(win)
+
+	ui.Label(win, pixel.Rect{Min: corner1, Max: corner2}, note.SpellingIn(st.Fifths).Title(), textColor)
+}
+
+func oppositeColor(c color.Color) color.Color {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{uint8(255 - r), uint8(255 - g), uint8(255 - b), 255}
+}
+
+// Progress bar at the bottom, progress is from 0 to 1
+func Progress(win ui.Canvas, progress float64) {
+	if progress > 1.0 {
+		progress = 1.0
+	}
+	if progress < 0.0 {
+		progress = 0.0
+	}
+	imd := imdraw.New(nil)
+	width := win.Bounds().W()
+
+	imd.Color = colornames.Black
+	imd.Push(
+		pixel.V(2, 2),
+		pixel.V(width-2, 10),
+	)
+	imd.Rectangle(1)
+
+	imd.Color = colornames.Red
+	imd.Push(
+		pixel.V(2, 2),
+		pixel.V(2+(width-5)*progress, 9),
+	)
+	imd.Rectangle(0)
+
+	imd.Draw(win)
+}
+
+// Lines of the staff and the time line
+func Lines(win ui.Canvas, st Staff) {
+	imd := imdraw.New(nil)
+	imd.Color = colornames.Black
+
+	width := win.Bounds().W()
+	height := win.Bounds().H()
+
+	for i := 0; i < 5; i++ {
+		imd.Push(
+			pixel.V(0, st.Bottom+float64(i)*st.Space),
+			pixel.V(width, st.Bottom+float64(i)*st.Space),
+		)
+		imd.Line(1)
+	}
+	imd.Push(
+		pixel.V(width*config.TimeLinePositionLatency, 0),
+		pixel.V(width*config.TimeLinePositionLatency, height),
+	)
+	imd.Line(3)
+
+	imd.Draw(win)
+}
+
+// Highlight the row of the note that is heard
+func Highlight(win ui.Canvas, st Staff, color color.Color, note notes.Pitch) {
+	imd := imdraw.New(nil)
+	width := win.Bounds().W()
+	if note.Name == "p" {
+		return
+	}
+	ycenter := st.Y(note)
+	radius := st.Space / 2
+	imd.Color = color
+
+	height := config.WhiteNoteWidth
+	if note.IsHalf {
+		height = config.BlackNoteWidth
+	}
+	imd.Push(
+		pixel.V(0, ycenter-height*radius+1),
+		pixel.V(width, ycenter+height*radius-1),
+	)
+	imd.Rectangle(0)
+	imd.Draw(win)
+}
@@ -0,0 +1,85 @@
+package highway
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/gameplay"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+var update = flag.Bool("update", false, "write golden images instead of comparing with them")
+
+// Channels could differ a bit, because of floating point rounding
+const tolerance = 2
+
+func TestHighway(t *testing.T) {
+	song, err := config.Song{Notes: "c d8 e8 f4. g8 fis a2 b c'2 bes"}.ParseNotes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	played := []gameplay.PlayedNote{
+		{SongNote: song[0], Correct: true},
+		{SongNote: song[1], Correct: true},
+		{SongNote: song[2]},
+	}
+	win := ui.NewOffscreen(pixel.R(0, 0, 800, 600))
+	win.Clear(config.BackgroundColor)
+	st := PianoRoll(win.Bounds())
+	Highlight(win, st, colornames.Salmon, song[3].Pitch)
+	Lines(win, st)
+	Notes(win, st, song, played, 1.5)
+	Progress(win, 0.25)
+	compareGolden(t, win, "testdata/highway.png")
+}
+
+func compareGolden(t *testing.T, win *ui.Offscreen, path string) {
+	if *update {
+		if err := win.SavePNG(path); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%s, run tests with -update to create it", err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := win.Image()
+	if golden.Bounds() != got.Bounds() {
+		t.Fatalf("size of image is %v, want %v", got.Bounds(), golden.Bounds())
+	}
+	different := 0
+	for y := got.Rect.Min.Y; y < got.Rect.Max.Y; y++ {
+		for x := got.Rect.Min.X; x < got.Rect.Max.X; x++ {
+			if !similar(got, golden, x, y) {
+				different++
+			}
+		}
+	}
+	if different > 0 {
+		win.SavePNG(path + ".failed.png")
+		t.Errorf("%d pixels differ from %s, see %s.failed.png", different, path, path)
+	}
+}
+
+func similar(got *image.RGBA, golden image.Image, x, y int) bool {
+	r1, g1, b1, a1 := got.At(x, y).RGBA()
+	r2, g2, b2, a2 := golden.At(x, y).RGBA()
+	for _, d := range []int{int(r1) - int(r2), int(g1) - int(g2), int(b1) - int(b2), int(a1) - int(a2)} {
+		if d>>8 > tolerance || -d>>8 > tolerance {
+			return false
+		}
+	}
+	return true
+}
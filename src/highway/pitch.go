@@ -0,0 +1,76 @@
+package highway
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+)
+
+// Y of any frequency, between the notes it is between, so it shows how far the sound is from them.
+// Notes of the flute range are at their Y, and it goes on evenly above and below them.
+func (st Staff) FrequencyY(frequency float64) float64 {
+	s := 12 * math.Log2(frequency/notes.C.Frequency) // semitones from the lowest c
+	i := int(math.Floor(s))
+	if i < 0 {
+		i = 0
+	}
+	if i > len(notes.FluteRange)-3 {
+		i = len(notes.FluteRange) - 3
+	}
+	y0, y1 := st.semitoneY(i), st.semitoneY(i+1)
+	return y0 + (y1-y0)*(s-float64(i))
+}
+
+// Frequency at the y, the opposite of FrequencyY
+func (st Staff) YFrequency(y float64) float64 {
+	i := 0
+	for i < len(notes.FluteRange)-3 && st.semitoneY(i+1) < y {
+		i++
+	}
+	y0, y1 := st.semitoneY(i), st.semitoneY(i+1)
+	s := float64(i) + (y-y0)/(y1-y0)
+	return notes.C.Frequency * math.Pow(2, s/12)
+}
+
+func (st Staff) semitoneY(i int) float64 {
+	return st.Y(notes.FluteRange[i+1]) // FluteRange starts with the pause
+}
+
+// TracePoint is the frequency heard at the time, negative for silence
+type TracePoint struct {
+	Time, Frequency float64
+}
+
+// Farthest the trace goes from the notes, in semitones. Further than that is not playing, but noise.
+const traceRange = 2
+
+// PitchTrace draws the line of frequencies heard, moving with the notes
+func PitchTrace(win ui.Canvas, st Staff, col color.Color, trace []TracePoint, time float64) {
+	low := notes.FluteRange[1].Frequency * math.Pow(2, -traceRange/12.0)
+	high := notes.FluteRange[len(notes.FluteRange)-1].Frequency * math.Pow(2, traceRange/12.0)
+	width := win.Bounds().W()
+	imd := imdraw.New(nil)
+	imd.Color = col
+	var line []pixel.Vec
+	end := func() { // line ends at silence
+		if len(line) > 1 {
+			imd.Push(line...)
+			imd.Line(3)
+		}
+		line = line[:0]
+	}
+	for _, p := range trace {
+		x := TimeX(width, p.Time, time)
+		if p.Frequency < low || p.Frequency > high || x < 0 {
+			end()
+			continue
+		}
+		line = append(line, pixel.V(x, st.FrequencyY(p.Frequency)))
+	}
+	end()
+	imd.Draw(win)
+}
@@ -0,0 +1,77 @@
+package highway
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/colornames"
+)
+
+func TestFrequencyY(t *testing.T) {
+	st := PianoRoll(pixel.R(0, 0, 800, 600))
+	for _, p := range notes.FluteRange[1:] {
+		assert.InDelta(t, st.Y(p), st.FrequencyY(p.Frequency), 0.01, p.Name) // table frequencies are rounded
+	}
+	// quarter tone above e is between e and f
+	e, f := notes.PitchByName["e"], notes.PitchByName["f"]
+	assert.InDelta(t, (st.Y(e)+st.Y(f))/2, st.FrequencyY(e.Frequency*math.Pow(2, 0.5/12)), 0.01)
+	for _, freq := range []float64{400, 523.25, 700, 1000, 2093, 2500} {
+		assert.InDelta(t, freq, st.YFrequency(st.FrequencyY(freq)), 1e-6)
+	}
+}
+
+func TestPitchTrace(t *testing.T) {
+	win := ui.NewOffscreen(pixel.R(0, 0, 800, 600))
+	win.Clear(config.BackgroundColor)
+	st := PianoRoll(win.Bounds())
+	e := notes.PitchByName["e"].Frequency
+
+	sg := NewSpectrogram(100, 1.0/60, 2)
+	var trace []TracePoint
+	for i := 0; i < 120; i++ {
+		time := float64(i) / 60
+		heard := -1.0
+		if i > 10 && i < 100 { // e, wobbling by quarter of a tone
+			heard = e * math.Pow(2, 0.5*math.Sin(float64(i)/8)/12)
+		}
+		trace = append(trace, TracePoint{Time: time, Frequency: heard})
+		sg.Add(st, win.Bounds().H(), time, func(frequency float64) float64 {
+			if heard < 0 {
+				return 0.0001
+			}
+			semitones := 12 * math.Log2(frequency/heard)
+			return 0.3*math.Exp(-semitones*semitones) + 0.001
+		})
+	}
+	sg.Draw(win, 2)
+	Lines(win, st)
+	PitchTrace(win, st, colornames.Red, trace, 2)
+	compareGolden(t, win, "testdata/pitch.png")
+}
+
+func TestSpectrogramFrameRate(t *testing.T) {
+	visible := config.TimeLinePosition / config.NoteSPS
+	for _, fps := range []float64{20, 60, 240} {
+		win := ui.NewOffscreen(pixel.R(0, 0, 800, 600))
+		win.Clear(colornames.White)
+		st := PianoRoll(win.Bounds())
+		sg := NewSpectrogram(50, 0.03, visible)
+		now := 0.0
+		for frame := 0; now < 3*visible; frame++ { // columns go around the ring few times
+			now = float64(frame) / fps
+			sg.Add(st, win.Bounds().H(), now, func(float64) float64 { return 1 })
+		}
+		sg.Draw(win, now)
+		img := win.Image()
+		// from the left of the screen to the time line, and nothing after it
+		for _, x := range []int{1, 120, int(800*config.TimeLinePosition) - 5} {
+			assert.NotEqual(t, img.At(x, 300), img.At(799, 300), "%f fps, x %d", fps, x)
+		}
+		assert.Equal(t, img.At(int(800*config.TimeLinePosition)+30, 300), img.At(799, 300), "%f fps", fps)
+	}
+}
@@ -0,0 +1,93 @@
+package highway
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+)
+
+// Loudness shown by the spectrogram, in dB of the full scale. Quieter is not shown, louder is the darkest.
+const (
+	spectrogramFloor   = -55.0
+	spectrogramCeiling = -10.0
+)
+
+// Spectrogram scrolls with the notes, and shows how loud is every frequency heard, by the darker color.
+// Rows of it are at the Y of their frequency, so sound of the note is on the row of the note.
+// Columns are added at the fixed time step, so they cover the same time at any frame rate.
+type Spectrogram struct {
+	step    float64            // seconds between columns
+	start   float64            // time of the first column
+	added   int                // columns since the start, the older ones are overwritten
+	pic     *pixel.PictureData // ring of columns, column i is at x = i % width
+	sprites []*pixel.Sprite    // of the parts of the ring, made again after columns are added
+	firsts  []int              // first column of each sprite
+}
+
+// NewSpectrogram with the given number of rows, and columns step seconds apart, enough to cover the visible seconds
+func NewSpectrogram(rows int, step, visible float64) *Spectrogram {
+	columns := int(math.Ceil(visible/step)) + 1
+	return &Spectrogram{step: step, pic: pixel.MakePictureData(pixel.R(0, 0, float64(columns), float64(rows)))}
+}
+
+// Due tells if it is time to Add the next column
+func (sg *Spectrogram) Due(time float64) bool {
+	return sg.added == 0 || time >= sg.start+float64(sg.added)*sg.step
+}
+
+// Add column of the sound heard at the time, and also for the steps skipped since the last one.
+// Magnitude gives magnitude of the frequency, 1 for the loudest sound.
+func (sg *Spectrogram) Add(st Staff, height, time float64, magnitude func(frequency float64) float64) {
+	if !sg.Due(time) {
+		return
+	}
+	if sg.added == 0 {
+		sg.start = time
+	}
+	width, rows := int(sg.pic.Rect.W()), int(sg.pic.Rect.H())
+	due := int((time-sg.start)/sg.step) + 1 // columns there should be by now
+	if due-sg.added > width {
+		sg.added = due - width // others would be overwritten anyway
+	}
+	for r := 0; r < rows; r++ {
+		y := (float64(r) + 0.5) * height / float64(rows)
+		db := 20 * math.Log10(magnitude(st.YFrequency(y)))
+		level := math.Max(0, math.Min(1, (db-spectrogramFloor)/(spectrogramCeiling-spectrogramFloor)))
+		// premultiplied dark blue, transparent for silence
+		c := color.RGBA{R: uint8(20 * level), G: uint8(40 * level), B: uint8(120 * level), A: uint8(200 * level)}
+		for i := sg.added; i < due; i++ {
+			sg.pic.Pix[sg.pic.Index(pixel.V(float64(i%width), float64(r)))] = c
+		}
+	}
+	sg.added = due
+	sg.sprites = nil
+}
+
+// Draw columns over the whole height of the window, each from its time for one step
+func (sg *Spectrogram) Draw(win ui.Canvas, time float64) {
+	width := int(sg.pic.Rect.W())
+	if sg.sprites == nil && sg.added > 0 {
+		// new picture, for the changed columns to be sent to the screen
+		pic := &pixel.PictureData{Pix: sg.pic.Pix, Stride: sg.pic.Stride, Rect: sg.pic.Rect}
+		first := max(0, sg.added-width)
+		sg.firsts = nil
+		for first < sg.added { // until the end of the ring, or the last column
+			x := first % width
+			n := min(width-x, sg.added-first)
+			sg.sprites = append(sg.sprites, pixel.NewSprite(pic, pixel.R(float64(x), 0, float64(x+n), sg.pic.Rect.H())))
+			sg.firsts = append(sg.firsts, first)
+			first += n
+		}
+	}
+	w, h := win.Bounds().W(), win.Bounds().H()
+	for i, sprite := range sg.sprites {
+		frame := sprite.Frame()
+		x0 := TimeX(w, sg.start+float64(sg.firsts[i])*sg.step, time)
+		x1 := TimeX(w, sg.start+(float64(sg.firsts[i])+frame.W())*sg.step, time)
+		sprite.Draw(win, pixel.IM.
+			ScaledXY(pixel.ZV, pixel.V((x1-x0)/frame.W(), h/frame.H())).
+			Moved(pixel.V((x0+x1)/2, h/2)))
+	}
+}
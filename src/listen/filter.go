@@ -0,0 +1,81 @@
+package listen
+
+import "math"
+
+// HighPass is a second order filter that removes frequencies below the cutoff,
+// like bass of the backing track, or noise of the wind
+type HighPass struct {
+	b0, b1, b2, a1, a2 float64 // coefficients
+	x1, x2, y1, y2     float64 // previous inputs and outputs
+}
+
+// See https://www.w3.org/TR/audio-eq-cookbook/
+func NewHighPass(sampleRate, cutoff float64) *HighPass {
+	w := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w) / math.Sqrt2 // Q = 1/sqrt(2), so there is no resonance
+	a0 := 1 + alpha
+	return &HighPass{
+		b0: (1 + math.Cos(w)) / 2 / a0,
+		b1: -(1 + math.Cos(w)) / a0,
+		b2: (1 + math.Cos(w)) / 2 / a0,
+		a1: -2 * math.Cos(w) / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Filter samples of the first channel in place
+func (f *HighPass) Filter(samples [][2]float64) {
+	for i := range samples {
+		x := samples[i][0]
+		y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+		f.x2, f.x1 = f.x1, x
+		f.y2, f.y1 = f.y1, y
+		samples[i][0] = y
+	}
+}
+
+// Gate ignores sounds that are not much louder than the background,
+// which level it learns from the sounds that were ignored.
+// Sounds of the game are background too: gate learns how loud they are heard, compared to how loud they were played,
+// but only when it is told that the player is silent, so it would not take the player for the game.
+type Gate struct {
+	Ratio float64 // how many times sound should be louder than background
+	floor float64 // level of the background
+	leak  float64 // level heard per level played by the game, 0 until the game is heard
+}
+
+// Game heard louder than that, compared to how loud it was played, is probably the player
+const maxLeak = 1.0
+
+// Open tells whether sound with this level is loud enough, when the game played sound of the reference level
+func (g *Gate) Open(level, reference float64) bool {
+	open := level > math.Max(g.floor, g.leak*reference)*g.Ratio
+	speed := 0.05 // background follows the sounds that are ignored
+	if open {
+		speed = 0.001 // and slowly follows the played notes, in case they are actually the background
+	}
+	g.floor += (level - g.floor) * speed
+	return open
+}
+
+// Calibrate learns how loud the game is heard, from the sound heard when the player is silent,
+// and the game played sound of the reference level.
+func (g *Gate) Calibrate(level, reference float64) {
+	if reference <= 0 {
+		return
+	}
+	leak := math.Min(level/reference, maxLeak)
+	if g.leak == 0 {
+		g.leak = leak
+	}
+	g.leak += (leak - g.leak) * 0.3
+}
+
+// Root mean square of the samples of the first channel
+func RMS(samples [][2]float64) float64 {
+	sum := 0.0
+	for _, s := range samples {
+		sum += s[0] * s[0]
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
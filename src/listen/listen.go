@@ -0,0 +1,92 @@
+// Package listen finds the pitch played in the sound of the microphone,
+// ignoring the background, and the sounds of the game heard from the speakers.
+package listen
+
+import (
+	"math"
+
+	"github.com/bunyk/fasolasi/src/yin"
+)
+
+// Lowest note of the recorder is 523 Hz, everything much lower than that is not played by the player
+const highPassCutoff = 400.0
+
+// Notes should be at least that many times louder than background, like the backing track
+const gateRatio = 3.0
+
+// Sound is taken as the one played by the game, when their pitches are closer than that, in any octave
+const echoCents = 50
+
+// Reference is the sound played by the game, to not take it for the player
+type Reference struct {
+	SampleRate int
+	Recent     func(samples [][2]float64) // fills samples with the latest sound played
+}
+
+// Listener finds pitch in the buffers of sound, one after another
+type Listener struct {
+	Probability float64 // certainty of the last pitch detected, from 0 to 1
+	Calibrating bool    // player is silent, so the game heard teaches how loud it is, and no pitch is found
+
+	detector    yin.Yin
+	highPass    *HighPass
+	gate        Gate
+	reference   Reference
+	refBuffer   [][2]float64
+	refDetector yin.Yin
+	refHighPass *HighPass
+}
+
+// NewListener for buffers of the given size. Reference could be empty, when the game plays nothing.
+// Reference buffer also covers the latency of the speakers, so it is longer than the one of microphone.
+func NewListener(sampleRate, bufSize int, reference Reference, latency float64) *Listener {
+	l := &Listener{
+		detector:  yin.NewYin(float64(sampleRate), bufSize, 0.05),
+		highPass:  NewHighPass(float64(sampleRate), highPassCutoff),
+		gate:      Gate{Ratio: gateRatio},
+		reference: reference,
+	}
+	if reference.Recent != nil {
+		size := int((float64(bufSize)/float64(sampleRate) + latency) * float64(reference.SampleRate))
+		l.refBuffer = make([][2]float64, size)
+		l.refDetector = yin.NewYin(float64(reference.SampleRate), size, 0.05)
+		l.refHighPass = NewHighPass(float64(reference.SampleRate), highPassCutoff)
+	}
+	return l
+}
+
+// Pitch played in the samples, or -1. Samples are changed by filtering.
+func (l *Listener) Pitch(samples [][2]float64) float64 {
+	l.highPass.Filter(samples)
+	pitch := l.detector.GetPitch2(samples)
+	l.Probability = l.detector.GetProbability()
+	l.detector.Clean()
+
+	reference, echo := 0.0, false
+	if l.reference.Recent != nil {
+		l.reference.Recent(l.refBuffer)
+		l.refHighPass.Filter(l.refBuffer)
+		reference = RMS(l.refBuffer)
+		if reference > 0 && pitch > 0 {
+			played := l.refDetector.GetPitch2(l.refBuffer)
+			l.refDetector.Clean()
+			echo = played > 0 && sameClass(pitch, played)
+		}
+	}
+	if l.Calibrating {
+		if echo { // it is surely the game heard, not the noise
+			l.gate.Calibrate(RMS(samples), reference)
+		}
+		return -1
+	}
+	if !l.gate.Open(RMS(samples), reference) {
+		return -1
+	}
+	return pitch
+}
+
+// Tells if frequencies are the same note, maybe in different octaves
+func sameClass(a, b float64) bool {
+	cents := math.Mod(math.Abs(1200*math.Log2(a/b)), 1200)
+	return cents < echoCents || cents > 1200-echoCents
+}
@@ -0,0 +1,109 @@
+package listen
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	micRate  = 48000
+	micSize  = micRate * 30 / 1000 // 30 ms
+	gameRate = 44100
+	delay    = 0.02 // from the game playing the sound, to the microphone hearing it
+)
+
+// Backing track, that changes notes and loudness
+type track struct {
+	notes   []float64 // frequencies
+	volumes []float64 // of each note
+	length  float64   // of each note in seconds
+}
+
+func (tr track) at(t float64) float64 {
+	if t < 0 {
+		return 0
+	}
+	i := int(t / tr.length)
+	f, v := tr.notes[i%len(tr.notes)], tr.volumes[i%len(tr.volumes)]
+	return v * (math.Sin(2*math.Pi*f*t) + 0.3*math.Sin(4*math.Pi*f*t))
+}
+
+// What the microphone hears
+type room struct {
+	track     track
+	leak      float64 // how loud the track is heard
+	player    float64 // frequency played, after the calibration
+	volume    float64 // of the player
+	calibrate float64 // seconds from the start, while the player is silent
+}
+
+// Pitches found after the calibration, in the given number of seconds
+func (r room) listen(seconds float64) []float64 {
+	now := 0.0
+	reference := Reference{SampleRate: gameRate, Recent: func(samples [][2]float64) {
+		for i := range samples {
+			samples[i][0] = r.track.at(now + float64(i-len(samples))/gameRate)
+		}
+	}}
+	l := NewListener(micRate, micSize, reference, 0.05)
+	noise := rand.New(rand.NewSource(1))
+	var pitches []float64
+	buffer := make([][2]float64, micSize)
+	for now < r.calibrate+seconds {
+		l.Calibrating = now < r.calibrate
+		for i := range buffer {
+			t := now + float64(i)/micRate
+			buffer[i][0] = r.leak*r.track.at(t-delay) + 0.002*noise.NormFloat64()
+			if !l.Calibrating {
+				buffer[i][0] += r.volume * math.Sin(2*math.Pi*r.player*t)
+			}
+		}
+		now += float64(micSize) / micRate
+		pitch := l.Pitch(buffer)
+		if !l.Calibrating {
+			pitches = append(pitches, pitch)
+		}
+	}
+	return pitches
+}
+
+func TestBackingIsNotHeard(t *testing.T) {
+	tr := track{
+		notes:   []float64{659, 880, 1047, 784, 262, 1319, 587},
+		volumes: []float64{0.8, 0.1, 0.5, 0.05, 0.9, 0.2, 0.6},
+		length:  0.4,
+	}
+	for i, p := range (room{track: tr, leak: 0.3, calibrate: 1}).listen(4) {
+		assert.Equal(t, -1.0, p, "buffer %d", i)
+	}
+}
+
+func TestPlayerIsHeardOverBacking(t *testing.T) {
+	tr := track{notes: []float64{659}, volumes: []float64{0.3}, length: 1}
+	pitches := room{track: tr, leak: 0.3, player: 1175, volume: 0.6, calibrate: 0.5}.listen(1)
+	for i, p := range pitches[1:] {
+		assert.InDelta(t, 1175, p, 10, "buffer %d", i+1)
+	}
+}
+
+func TestUnison(t *testing.T) {
+	// player plays the melody together with the game, and is not taken for it
+	tr := track{notes: []float64{880}, volumes: []float64{0.5}, length: 1}
+	for _, leak := range []float64{0, 0.05} {
+		pitches := room{track: tr, leak: leak, player: 880, volume: 0.6, calibrate: 0.5}.listen(1.5)
+		for i, p := range pitches[1:] {
+			assert.InDelta(t, 880, p, 10, "leak %f, buffer %d", leak, i+1)
+		}
+	}
+}
+
+func TestSameClass(t *testing.T) {
+	assert.True(t, sameClass(440, 440))
+	assert.True(t, sameClass(440, 880*1.01))
+	assert.True(t, sameClass(880, 220/1.01))
+	assert.False(t, sameClass(440, 466))
+	assert.False(t, sameClass(440, 660))
+}
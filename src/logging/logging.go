@@ -0,0 +1,110 @@
+// Package logging writes leveled structured log of the game to the console and to a rotating file
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// When log file grows larger than that, it is renamed to .1, and the older ones to .2, .3...
+const (
+	MaxSize = 1 << 20 // bytes
+	Backups = 3
+)
+
+// RotatingFile is a log file, that keeps at most backups older files of maxSize
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotating opens the file to append to it
+func OpenRotating(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(r.backup(r.backups))
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(r.backup(i), r.backup(i+1))
+	}
+	if r.backups > 0 {
+		os.Rename(r.path, r.backup(1))
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// ParseLevel reads level by its name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(strings.ToUpper(name)))
+	return l, err
+}
+
+// Setup makes the default logger write records of the level and higher to the console, and to the rotating file.
+// Messages of the standard log package go there too, as info.
+// Returned file should be closed when the game ends.
+func Setup(path string, level slog.Level, console io.Writer) (io.Closer, error) {
+	file, err := OpenRotating(path, MaxSize, Backups)
+	if err != nil {
+		slog.SetDefault(slog.New(slog.NewTextHandler(console, &slog.HandlerOptions{Level: level})))
+		return nil, err
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.MultiWriter(console, file), &slog.HandlerOptions{Level: level})))
+	return file, nil
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	r, err := OpenRotating(path, 10, 2)
+	assert.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := r.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, r.Close())
+
+	read := func(p string) string {
+		data, _ := os.ReadFile(p)
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only two backups are kept")
+
+	// appends to the existing file
+	r, err = OpenRotating(path, 10, 2)
+	assert.NoError(t, err)
+	r.Write([]byte("5\n"))
+	r.Close()
+	assert.Equal(t, "fourth\n5\n", read(path))
+}
+
+func TestSetup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	path := filepath.Join(t.TempDir(), "game.log")
+	var console strings.Builder
+	f, err := Setup(path, slog.LevelInfo, &console)
+	assert.NoError(t, err)
+	slog.Debug("hidden")
+	slog.Info("session started", "song", "A short one")
+	f.Close()
+
+	data, _ := os.ReadFile(path)
+	assert.Contains(t, string(data), `msg="session started" song="A short one"`)
+	assert.NotContains(t, string(data), "hidden")
+	assert.Equal(t, string(data), console.String())
+
+	level, err := ParseLevel("debug")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+}
@@ -0,0 +1,31 @@
+package notes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sharps in the key signature of major keys, negative for flats
+var majorFifths = map[string]int{
+	"ces": -7, "ges": -6, "des": -5, "as": -4, "es": -3, "bes": -2, "f": -1,
+	"c": 0, "g": 1, "d": 2, "a": 3, "e": 4, "b": 5, "fis": 6, "cis": 7,
+}
+
+// KeyFifths returns number of sharps in key signature, or minus number of flats.
+// Key is the name of the tonic, like "g" or "bes", with " minor" for minor keys: "e minor".
+// Empty key is C major.
+func KeyFifths(key string) (int, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return 0, nil
+	}
+	tonic := strings.TrimSuffix(key, " minor")
+	fifths, ok := majorFifths[tonic]
+	if !ok {
+		return 0, fmt.Errorf("Unknown key: %#v", key)
+	}
+	if tonic != key { // relative major is a minor third higher
+		fifths -= 3
+	}
+	return fifths, nil
+}
@@ -1,6 +1,7 @@
 package notes
 
 import (
+	"math"
 	"strings"
 )
 
@@ -67,7 +68,13 @@ func (p Pitch) HasAdditionalLine() bool {
 	return int(p.Bottom*4)%4 == 0
 }
 
+var semitone = math.Pow(2, 1.0/12)
+
 func GuessNote(frequency float64) (Pitch, int) {
+	// Sounds more than a semitone outside of the range are not notes played, but noise, or sounds of the game
+	if frequency < FluteRange[1].Frequency/semitone || frequency > FluteRange[len(FluteRange)-1].Frequency*semitone {
+		return Pause, 0
+	}
 	min := 0
 	max := len(FluteRange) - 1
 	for {
@@ -94,10 +101,26 @@ func GuessNote(frequency float64) (Pitch, int) {
 	}
 }
 
+// Cents how much frequency is higher than the pitch, negative if lower. 100 cents is a semitone.
+func (p Pitch) Cents(frequency float64) float64 {
+	return 1200 * math.Log2(frequency/p.Frequency)
+}
+
 type SongNote struct {
 	Pitch    Pitch
 	Time     float64
 	Duration float64
+	Value    int      // as written: 1 for whole note, 2 for half, 4 for quarter...
+	Dotted   bool     // written with dot
+	Spelling Spelling // as written in the song, unknown for notes played
+}
+
+// Spelling as written, or the one for the key, with given number of sharps, or flats when negative
+func (sn SongNote) SpellingIn(fifths int) Spelling {
+	if sn.Spelling.Letter != 0 {
+		return sn.Spelling
+	}
+	return Spell(sn.Pitch, fifths)
 }
 
 func (sn SongNote) End() float64 {
@@ -0,0 +1,28 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCents(t *testing.T) {
+	assert.InDelta(t, 0, C.Cents(C.Frequency), 1e-9)
+	assert.InDelta(t, 100, C.Cents(FluteRange[2].Frequency), 0.5)
+	assert.InDelta(t, -1200, C.Cents(C.Frequency/2), 1e-9)
+}
+
+func TestGuessNote(t *testing.T) {
+	p, _ := GuessNote(C.Frequency * 1.01)
+	assert.Equal(t, "c", p.Name)
+	p, _ = GuessNote(C.Frequency / 1.03) // a bit flat is still c
+	assert.Equal(t, "c", p.Name)
+	p, _ = GuessNote(2093 * 1.05)
+	assert.Equal(t, "c''", p.Name)
+
+	// accompaniment two octaves lower, or whistles above the flute, are not notes played
+	for _, f := range []float64{C.Frequency / 4, C.Frequency / 1.1, 2093 * 1.1, 8000, -1} {
+		p, _ = GuessNote(f)
+		assert.Equal(t, Pause, p, "%f", f)
+	}
+}
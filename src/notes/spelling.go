@@ -0,0 +1,143 @@
+package notes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Spelling is how the pitch is written: cis and des sound the same, but are written differently
+type Spelling struct {
+	Letter     byte // from 'c' to 'b', 0 when spelling is unknown
+	Alteration int  // 1 for sharp, -1 for flat, 2 and -2 for double ones
+	Octave     int  // number of ' in the name
+}
+
+// Semitones from c, and positions on the staff, of the natural notes in the first octave
+var (
+	letterSemitones = map[byte]int{'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11}
+	letterPositions = map[byte]float64{'c': -1, 'd': -0.5, 'e': 0, 'f': 0.5, 'g': 1, 'a': 1.5, 'b': 2}
+)
+
+// Natural notes by semitones from c
+var letterSemitoneNames = map[int]byte{0: 'c', 2: 'd', 4: 'e', 5: 'f', 7: 'g', 9: 'a', 11: 'b'}
+
+// Endings of the name for alterations. "es" and "as" are short for "ees" and "aes".
+var alterationSuffixes = map[string]int{"": 0, "is": 1, "isis": 2, "es": -1, "eses": -2}
+
+// ParseSpelling reads name of the note in LilyPond notation:
+// letter, "is" for sharp or "es" for flat, and ' for every octave up. Like "fis", "bes'" or "as".
+func ParseSpelling(name string) (Spelling, error) {
+	base := strings.TrimRight(name, "'")
+	sp := Spelling{Octave: len(name) - len(base)}
+	if base == "" || letterSemitones[base[0]] == 0 && base[0] != 'c' {
+		return sp, fmt.Errorf("Unknown note: %#v", name)
+	}
+	sp.Letter = base[0]
+	suffix := base[1:]
+	if (sp.Letter == 'e' || sp.Letter == 'a') && strings.HasPrefix(suffix, "s") {
+		suffix = "e" + suffix
+	}
+	alteration, ok := alterationSuffixes[suffix]
+	if !ok {
+		return sp, fmt.Errorf("Unknown note: %#v", name)
+	}
+	sp.Alteration = alteration
+	return sp, nil
+}
+
+// Name in the LilyPond notation
+func (sp Spelling) Name() string {
+	if sp.Letter == 0 {
+		return ""
+	}
+	suffix := ""
+	for name, alteration := range alterationSuffixes {
+		if alteration == sp.Alteration {
+			suffix = name
+		}
+	}
+	if (sp.Letter == 'e' || sp.Letter == 'a') && strings.HasPrefix(suffix, "es") {
+		suffix = suffix[1:]
+	}
+	if sp.Octave < 0 { // below the range, written like in LilyPond
+		return string(sp.Letter) + suffix + strings.Repeat(",", -sp.Octave)
+	}
+	return string(sp.Letter) + suffix + strings.Repeat("'", sp.Octave)
+}
+
+// Title to show, like "F#" or "Gb"
+func (sp Spelling) Title() string {
+	t := strings.ToUpper(string(sp.Letter))
+	if sp.Alteration > 0 {
+		t += strings.Repeat("#", sp.Alteration)
+	} else {
+		t += strings.Repeat("b", -sp.Alteration)
+	}
+	return t
+}
+
+// Position on the staff, like Pitch.Bottom, but the same for all alterations of the letter
+func (sp Spelling) Position() float64 {
+	return letterPositions[sp.Letter] + 3.5*float64(sp.Octave)
+}
+
+// Natural note of the same letter and octave
+func (sp Spelling) Natural() Spelling {
+	return Spelling{Letter: sp.Letter, Octave: sp.Octave}
+}
+
+// Semitones from the lowest c, could be negative for notes below it
+func (sp Spelling) Semitones() int {
+	return letterSemitones[sp.Letter] + sp.Alteration + 12*sp.Octave
+}
+
+// Pitch that sounds, false when it is out of the range of the flute
+func (sp Spelling) Pitch() (Pitch, bool) {
+	i := sp.Semitones() + 1 // FluteRange starts with the pause
+	if i < 1 || i >= len(FluteRange) {
+		return Pause, false
+	}
+	return FluteRange[i], true
+}
+
+// Semitones from the lowest c
+func (p Pitch) semitones() int {
+	return int(math.Round(12 * math.Log2(p.Frequency/C.Frequency)))
+}
+
+// Spell pitch in the key with the given number of sharps, or flats when negative.
+// Black keys are written with sharps in the keys with sharps, and with flats in the keys with flats.
+// In C major they are written as in FluteRange.
+func Spell(p Pitch, fifths int) Spelling {
+	if p.Frequency < 0 {
+		return Spelling{}
+	}
+	return spellSemitones(p.semitones(), fifths)
+}
+
+// Transpose moves the note by semitones, and spells it in the key with given fifths
+func (sp Spelling) Transpose(semitones, fifths int) Spelling {
+	if semitones == 0 {
+		return sp
+	}
+	return spellSemitones(sp.Semitones()+semitones, fifths)
+}
+
+func spellSemitones(semitones, fifths int) Spelling {
+	octave := int(math.Floor(float64(semitones) / 12))
+	semitone := semitones - 12*octave
+	if _, white := letterSemitoneNames[semitone]; !white {
+		flat := semitone == 10 // only bes is flat in FluteRange
+		if fifths != 0 {
+			flat = fifths < 0
+		}
+		if flat {
+			semitone++ // of the natural note, that is lowered
+		} else {
+			semitone--
+		}
+	}
+	letter := letterSemitoneNames[semitone]
+	return Spelling{Letter: letter, Alteration: semitones - 12*octave - letterSemitones[letter], Octave: octave}
+}
@@ -0,0 +1,81 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpelling(t *testing.T) {
+	for name, sounds := range map[string]string{
+		"c": "c", "des": "cis", "es": "dis", "eis": "f", "fes": "e",
+		"as": "gis", "ais": "bes", "ces'": "b", "bis": "c'", "eses'": "d'",
+		"gisis": "a", "c''": "c''",
+	} {
+		sp, err := ParseSpelling(name)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.Equal(t, name, sp.Name())
+		p, ok := sp.Pitch()
+		assert.True(t, ok, name)
+		assert.Equal(t, sounds, p.Name, name)
+	}
+	for _, name := range []string{"h", "cs", "bess", "'"} {
+		_, err := ParseSpelling(name)
+		assert.Error(t, err, name)
+	}
+	sp, err := ParseSpelling("ces")
+	assert.NoError(t, err)
+	_, ok := sp.Pitch()
+	assert.False(t, ok, "ces is below the range")
+}
+
+func TestSpell(t *testing.T) {
+	cis, _ := ParseSpelling("cis'")
+	p, _ := cis.Pitch()
+	assert.Equal(t, "C#", Spell(p, 0).Title())
+	assert.Equal(t, "C#", Spell(p, 2).Title())
+	assert.Equal(t, "Db", Spell(p, -1).Title())
+	assert.Equal(t, "des'", Spell(p, -1).Name())
+
+	bes, _ := ParseSpelling("bes")
+	p, _ = bes.Pitch()
+	assert.Equal(t, "Bb", Spell(p, 0).Title())
+	assert.Equal(t, "A#", Spell(p, 1).Title())
+	assert.Equal(t, 1.5, Spell(p, 1).Position())
+
+	assert.Equal(t, "c''", Spell(FluteRange[len(FluteRange)-1], 3).Name())
+	assert.Equal(t, Spelling{}, Spell(Pause, 0))
+}
+
+func TestKeyFifths(t *testing.T) {
+	for key, fifths := range map[string]int{"": 0, "g": 1, "bes": -2, "e minor": 1, "c minor": -3, "fis minor": 3} {
+		f, err := KeyFifths(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, fifths, f, key)
+	}
+	_, err := KeyFifths("h")
+	assert.Error(t, err)
+}
+
+func TestTranspose(t *testing.T) {
+	for _, c := range []struct {
+		from      string
+		semitones int
+		fifths    int
+		to        string
+	}{
+		{"c", 2, 2, "d"},
+		{"f", 1, 2, "fis"},
+		{"f", 1, -1, "ges"},
+		{"b", 1, 0, "c'"},
+		{"c'", -1, 0, "b"},
+		{"fis'", 0, -3, "fis'"}, // written as it is, when not transposed
+		{"e", -7, 0, "a,"},
+	} {
+		sp, err := ParseSpelling(c.from)
+		assert.NoError(t, err)
+		assert.Equal(t, c.to, sp.Transpose(c.semitones, c.fifths).Name(), "%s by %d", c.from, c.semitones)
+	}
+}
@@ -0,0 +1,76 @@
+package sheet
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/config"
+)
+
+// Command exports song from the config as sheet music:
+// fasolasi sheet [--width 800] "Song name" song.svg
+// Format is chosen by extension of the file: .svg or .png
+func Command(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sheet", flag.ContinueOnError)
+	fs.SetOutput(out)
+	width := fs.Float64("width", 800, "width of the page in pixels")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: fasolasi sheet [flags] \"song name\" file.svg|file.png")
+		fs.PrintDefaults()
+	}
+
+	// flags could go before or after the arguments, like in transcribe
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected name of the song and output file")
+	}
+	name, path := positional[0], positional[1]
+	var song *config.Song
+	for i := range config.Songs {
+		if config.Songs[i].Name == name {
+			song = &config.Songs[i]
+		}
+	}
+	if song == nil {
+		return fmt.Errorf("song %#v not found in %s", name, config.ConfigFileName)
+	}
+	s, err := New(*song, *width)
+	if err != nil {
+		return err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".svg" && ext != ".png" {
+		return fmt.Errorf("unsupported format of %s, use .svg or .png", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if ext == ".svg" {
+		err = s.WriteSVG(f)
+	} else {
+		err = png.Encode(f, s.Image())
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
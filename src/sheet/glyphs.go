@@ -0,0 +1,250 @@
+package sheet
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Staff to draw on. Glyphs are measured in spaces between staff lines:
+// x from the start of the staff, y (position) from its bottom line up.
+type staff struct {
+	p      Painter
+	origin pixel.Vec // left end of the bottom line, in pixels
+	space  float64   // between lines, in pixels
+}
+
+func (st staff) at(x, pos float64) pixel.Vec {
+	return st.origin.Add(pixel.V(x, pos).Scaled(st.space))
+}
+
+func (st staff) pixels(points []pixel.Vec) []pixel.Vec {
+	result := make([]pixel.Vec, len(points))
+	for i, v := range points {
+		result[i] = st.at(v.X, v.Y)
+	}
+	return result
+}
+
+func (st staff) line(width float64, points ...pixel.Vec) {
+	st.p.Polyline(width*st.space, st.pixels(points)...)
+}
+
+func (st staff) fill(points ...pixel.Vec) {
+	st.p.Polygon(st.pixels(points)...)
+}
+
+func (st staff) text(x, pos, size float64, s string) {
+	st.p.Text(st.at(x, pos), size*st.space, s)
+}
+
+// Five lines from x1 to x2
+func (st staff) lines(x1, x2 float64) {
+	for i := 0.0; i < 5; i++ {
+		st.line(lineWidth, pixel.V(x1, i), pixel.V(x2, i))
+	}
+}
+
+const (
+	lineWidth  = 0.1
+	stemWidth  = 0.12
+	stemLength = 3.5
+)
+
+// Smooth curve going through the points
+func curve(steps int, points ...pixel.Vec) []pixel.Vec {
+	result := []pixel.Vec{points[0]}
+	for i := 0; i+1 < len(points); i++ {
+		p0, p1, p2, p3 := points[i], points[i], points[i+1], points[i+1]
+		if i > 0 {
+			p0 = points[i-1]
+		}
+		if i+2 < len(points) {
+			p3 = points[i+2]
+		}
+		for j := 1; j <= steps; j++ { // Catmull-Rom spline
+			t := float64(j) / float64(steps)
+			t2, t3 := t*t, t*t*t
+			result = append(result, p1.Scaled(2).
+				Add(p2.Sub(p0).Scaled(t)).
+				Add(p0.Scaled(2).Sub(p1.Scaled(5)).Add(p2.Scaled(4)).Sub(p3).Scaled(t2)).
+				Add(p1.Scaled(3).Sub(p0).Sub(p2.Scaled(3)).Add(p3).Scaled(t3)).
+				Scaled(0.5))
+		}
+	}
+	return result
+}
+
+func ellipse(center pixel.Vec, rx, ry, angle float64) []pixel.Vec {
+	const n = 24
+	points := make([]pixel.Vec, n+1)
+	for i := range points {
+		a := 2 * math.Pi * float64(i) / n
+		points[i] = center.Add(pixel.V(rx*math.Cos(a), ry*math.Sin(a)).Rotated(angle))
+	}
+	return points
+}
+
+// Treble clef, curled around the G line
+func (st staff) trebleClef(x float64) {
+	center := pixel.V(x+1.3, 1)
+	var spiral []pixel.Vec
+	for a := 0.0; a <= 3.5*math.Pi; a += math.Pi / 12 { // from the top counterclockwise
+		r := 0.3 + a/(2*math.Pi)*0.55
+		spiral = append(spiral, center.Add(pixel.V(-r*math.Sin(a), r*math.Cos(a))))
+	}
+	st.line(0.18, spiral...)
+	end := spiral[len(spiral)-1]
+	st.line(0.18, curve(8,
+		end,
+		pixel.V(x+2.3, 2.3),
+		pixel.V(x+1.5, 3.6),
+		pixel.V(x+1.1, 4.7),
+		pixel.V(x+1.35, 5.6),
+		pixel.V(x+1.8, 5.2),
+		pixel.V(x+1.6, 4.3),
+		pixel.V(x+1.15, 3.2),
+		pixel.V(x+1.25, 0.5),
+		pixel.V(x+1.4, -0.9),
+		pixel.V(x+1.0, -1.45),
+		pixel.V(x+0.45, -1.1),
+	)...)
+	st.fill(ellipse(pixel.V(x+0.7, -0.95), 0.3, 0.3, 0)...)
+}
+
+const (
+	natural = iota
+	sharp
+	flat
+	doubleSharp
+	doubleFlat
+)
+
+// Width taken by the accidental before the note
+const accidentalWidth = 1.1
+
+func (st staff) accidental(x, pos float64, kind int) {
+	switch kind {
+	case sharp:
+		st.line(lineWidth, pixel.V(x-0.2, pos-1.3), pixel.V(x-0.2, pos+1.1))
+		st.line(lineWidth, pixel.V(x+0.2, pos-1.1), pixel.V(x+0.2, pos+1.3))
+		st.fill(pixel.V(x-0.45, pos+0.2), pixel.V(x+0.45, pos+0.5), pixel.V(x+0.45, pos+0.75), pixel.V(x-0.45, pos+0.45))
+		st.fill(pixel.V(x-0.45, pos-0.6), pixel.V(x+0.45, pos-0.3), pixel.V(x+0.45, pos-0.05), pixel.V(x-0.45, pos-0.35))
+	case flat:
+		st.line(lineWidth, pixel.V(x-0.3, pos-0.5), pixel.V(x-0.3, pos+2))
+		st.line(0.15, curve(4,
+			pixel.V(x-0.3, pos+0.1),
+			pixel.V(x+0.05, pos+0.55),
+			pixel.V(x+0.35, pos+0.3),
+			pixel.V(x-0.3, pos-0.5),
+		)...)
+	case doubleSharp:
+		st.line(0.15, pixel.V(x-0.35, pos-0.35), pixel.V(x+0.35, pos+0.35))
+		st.line(0.15, pixel.V(x-0.35, pos+0.35), pixel.V(x+0.35, pos-0.35))
+	case doubleFlat:
+		st.accidental(x-0.35, pos, flat)
+		st.accidental(x+0.25, pos, flat)
+	case natural:
+		st.line(lineWidth, pixel.V(x-0.25, pos-0.5), pixel.V(x-0.25, pos+1.3))
+		st.line(lineWidth, pixel.V(x+0.25, pos-1.3), pixel.V(x+0.25, pos+0.5))
+		st.fill(pixel.V(x-0.25, pos+0.2), pixel.V(x+0.25, pos+0.45), pixel.V(x+0.25, pos+0.7), pixel.V(x-0.25, pos+0.45))
+		st.fill(pixel.V(x-0.25, pos-0.6), pixel.V(x+0.25, pos-0.35), pixel.V(x+0.25, pos-0.1), pixel.V(x-0.25, pos-0.35))
+	}
+}
+
+// Positions of sharps and flats in the key signature, in the order they are added
+var (
+	sharpPositions = []float64{4, 2.5, 4.5, 3, 1.5, 3.5, 2}
+	flatPositions  = []float64{2, 3.5, 1.5, 3, 1, 2.5, 0.5}
+)
+
+// Draw key signature, and return its width
+func (st staff) keySignature(x float64, fifths int) float64 {
+	for i := 0; i < fifths; i++ {
+		st.accidental(x+0.5+float64(i), sharpPositions[i], sharp)
+	}
+	for i := 0; i < -fifths; i++ {
+		st.accidental(x+0.5+float64(i), flatPositions[i], flat)
+	}
+	return math.Abs(float64(fifths))
+}
+
+func (st staff) timeSignature(x float64, beats, unit int) {
+	st.text(x, 3, 2.8, fmt.Sprint(beats))
+	st.text(x, 1, 2.8, fmt.Sprint(unit))
+}
+
+// Lines for notes above or below the staff
+func (st staff) ledgerLines(x, pos float64) {
+	for l := -1.0; l >= pos; l-- {
+		st.line(lineWidth, pixel.V(x-1, l), pixel.V(x+1, l))
+	}
+	for l := 5.0; l <= pos; l++ {
+		st.line(lineWidth, pixel.V(x-1, l), pixel.V(x+1, l))
+	}
+}
+
+func (st staff) head(x, pos float64, value int) {
+	c := pixel.V(x, pos)
+	switch {
+	case value <= 1:
+		st.line(0.2, ellipse(c, 0.65, 0.4, 0)...)
+	case value == 2:
+		st.line(0.16, ellipse(c, 0.58, 0.38, 0.35)...)
+	default:
+		st.fill(ellipse(c, 0.65, 0.45, 0.35)...)
+	}
+}
+
+// Dot after the note, moved to the space if note is on the line
+func (st staff) dot(x, pos float64) {
+	if math.Mod(pos+10, 1) == 0 {
+		pos += 0.5
+	}
+	st.fill(ellipse(pixel.V(x+1, pos), 0.18, 0.18, 0)...)
+}
+
+// Flags of the stem, end is the free end of the stem
+func (st staff) flags(end pixel.Vec, up bool, count int) {
+	dir := -1.0
+	if !up {
+		dir = 1
+	}
+	for i := 0; i < count; i++ {
+		top := end.Add(pixel.V(0, dir*0.8*float64(i)))
+		st.line(0.2, curve(4,
+			top,
+			top.Add(pixel.V(0.4, dir*0.9)),
+			top.Add(pixel.V(0.8, dir*1.9)),
+			top.Add(pixel.V(0.6, dir*2.6)),
+		)...)
+	}
+}
+
+func (st staff) rest(x float64, value int) {
+	switch {
+	case value <= 1: // hangs from the fourth line
+		st.fill(pixel.V(x-0.55, 3), pixel.V(x+0.55, 3), pixel.V(x+0.55, 2.5), pixel.V(x-0.55, 2.5))
+	case value == 2: // lies on the middle line
+		st.fill(pixel.V(x-0.55, 2), pixel.V(x+0.55, 2), pixel.V(x+0.55, 2.5), pixel.V(x-0.55, 2.5))
+	case value == 4:
+		st.line(0.25,
+			pixel.V(x-0.3, 3.4), pixel.V(x+0.3, 2.7), pixel.V(x-0.2, 2.1),
+			pixel.V(x+0.3, 1.5), pixel.V(x-0.3, 1.4), pixel.V(x, 0.9),
+		)
+	default:
+		count := 0
+		for v := value; v >= 8; v /= 2 {
+			count++
+		}
+		top := 2.8 + 0.5*float64(count-1)
+		st.line(0.15, pixel.V(x+0.35, top), pixel.V(x-0.15, top-1.2-float64(count)*0.6))
+		for i := 0; i < count; i++ {
+			y := top - float64(i)*0.8
+			dx := -0.25 * float64(i)
+			st.fill(ellipse(pixel.V(x-0.25+dx, y), 0.2, 0.2, 0)...)
+			st.line(0.12, curve(3, pixel.V(x-0.25+dx, y-0.1), pixel.V(x+0.05+dx, y-0.15), pixel.V(x+0.35+dx, y))...)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sheet
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+// Painter draws shapes the sheet is made of, in black.
+// Coordinates go up and right, like in pixel.
+type Painter interface {
+	Polyline(width float64, points ...pixel.Vec)
+	Polygon(points ...pixel.Vec) // filled
+	Text(center pixel.Vec, size float64, s string)
+}
+
+// Draws on the window, or off-screen
+type canvasPainter struct {
+	canvas ui.Canvas
+}
+
+func NewCanvasPainter(c ui.Canvas) Painter {
+	return canvasPainter{canvas: c}
+}
+
+func (p canvasPainter) Polyline(width float64, points ...pixel.Vec) {
+	imd := imdraw.New(nil)
+	imd.Color = colornames.Black
+	imd.EndShape = imdraw.SharpEndShape
+	imd.Push(points...)
+	imd.Line(width)
+	imd.Draw(p.canvas)
+}
+
+func (p canvasPainter) Polygon(points ...pixel.Vec) {
+	imd := imdraw.New(nil)
+	imd.Color = colornames.Black
+	imd.Push(points...)
+	imd.Polygon(0)
+	imd.Draw(p.canvas)
+}
+
+func (p canvasPainter) Text(center pixel.Vec, size float64, s string) {
+	txt := text.New(pixel.ZV, ui.TextAtlas)
+	txt.Color = colornames.Black
+	fmt.Fprint(txt, s)
+	scale := size / ui.TextAtlas.LineHeight()
+	// center of the capital letters, not of the line with descenders
+	middle := pixel.V(txt.Bounds().W()/2, ui.TextAtlas.Ascent()*0.35)
+	txt.Draw(p.canvas, pixel.IM.Moved(middle.Scaled(-1)).Scaled(pixel.ZV, scale).Moved(center))
+}
+
+// Collects SVG elements, to write them when the size of the image is known
+type svgPainter struct {
+	height float64
+	sb     strings.Builder
+}
+
+func (p *svgPainter) points(points []pixel.Vec) string {
+	coords := make([]string, len(points))
+	for i, v := range points {
+		coords[i] = fmt.Sprintf("%.2f,%.2f", v.X, p.height-v.Y)
+	}
+	return strings.Join(coords, " ")
+}
+
+func (p *svgPainter) Polyline(width float64, points ...pixel.Vec) {
+	fmt.Fprintf(&p.sb, "<polyline points=\"%s\" fill=\"none\" stroke=\"black\" stroke-width=\"%.2f\"/>\n", p.points(points), width)
+}
+
+func (p *svgPainter) Polygon(points ...pixel.Vec) {
+	fmt.Fprintf(&p.sb, "<polygon points=\"%s\"/>\n", p.points(points))
+}
+
+func (p *svgPainter) Text(center pixel.Vec, size float64, s string) {
+	fmt.Fprintf(&p.sb,
+		"<text x=\"%.2f\" y=\"%.2f\" font-size=\"%.2f\" text-anchor=\"middle\" dominant-baseline=\"central\">%s</text>\n",
+		center.X, p.height-center.Y, size, html.EscapeString(s),
+	)
+}
+
+func (p *svgPainter) WriteTo(w io.Writer, width float64) error {
+	_, err := fmt.Fprintf(w,
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" font-family=\"serif\">\n"+
+			"<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n%s</svg>\n",
+		width, p.height, width, p.height, p.sb.String(),
+	)
+	return err
+}
@@ -0,0 +1,92 @@
+package sheet
+
+import (
+	"math"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+)
+
+// Scroll is the song in sheet music, moving past the time line during the game
+type Scroll struct {
+	signature
+	bars  []bar
+	start float64 // time of the first note, in seconds
+	whole float64 // duration of the whole note, in seconds
+}
+
+// NewScroll lays out notes of the game session, played in the given tempo.
+// Song gives key and time signatures.
+func NewScroll(song config.Song, session []notes.SongNote, bpm int) (*Scroll, error) {
+	sig, err := songSignature(song)
+	if err != nil {
+		return nil, err
+	}
+	sc := &Scroll{signature: sig, whole: 240.0 / float64(bpm)}
+	for _, n := range session {
+		if n.Value > 0 { // not the note to start the game
+			sc.start = n.Time
+			break
+		}
+	}
+	sc.bars = splitBars(session, sc.barLength())
+	for i := range sc.bars {
+		sc.placeAccidentals(&sc.bars[i])
+	}
+	return sc, nil
+}
+
+// Draw notes on the staff with bottom line at y = bottom.
+// Function x gives position of the moment in time on the screen.
+// Clef and key signature stay on the left, and notes pass under them.
+func (sc *Scroll) Draw(c ui.Canvas, bottom, space float64, x func(time float64) float64) {
+	st := staff{p: NewCanvasPainter(c), origin: pixel.V(0, bottom), space: space}
+	width := c.Bounds().W() / space
+	header := clefWidth + math.Abs(float64(sc.fifths)) + 0.5
+
+	st.timeSignature(x(sc.start)/space-timeWidth, sc.beats, sc.unit)
+	var previous *item // last drawn note, to draw ties from it
+	for i, b := range sc.bars {
+		barStart := sc.start + float64(i)*sc.barLength()*sc.whole
+		barEnd := x(barStart+sc.barLength()*sc.whole) / space
+		if barEnd < 0 {
+			continue
+		}
+		if x(barStart)/space > width {
+			break
+		}
+		items := append([]item(nil), b.items...)
+		for k := range items {
+			items[k].x = x(barStart+items[k].start*sc.whole) / space
+			if previous != nil && previous.tied {
+				drawTie(st, *previous, items[k].x-0.6)
+			}
+			previous = &items[k]
+		}
+		sc.drawBar(st, items)
+		if i+1 == len(sc.bars) {
+			st.line(0.1, pixel.V(barEnd-0.35, 0), pixel.V(barEnd-0.35, 4))
+			st.line(0.5, pixel.V(barEnd, 0), pixel.V(barEnd, 4))
+			continue
+		}
+		// next bar starts with the note, so the line goes before it
+		barEnd -= 1.2
+		if sc.bars[i+1].items[0].accidental >= 0 {
+			barEnd -= accidentalWidth
+		}
+		st.line(0.1, pixel.V(barEnd, 0), pixel.V(barEnd, 4))
+	}
+
+	// hide notes that went under the clef
+	imd := imdraw.New(nil)
+	imd.Color = config.BackgroundColor
+	imd.Push(st.at(0, -3), st.at(header, 7))
+	imd.Rectangle(0)
+	imd.Draw(c)
+	st.lines(0, header)
+	st.trebleClef(0.3)
+	st.keySignature(clefWidth-0.3, sc.fifths)
+}
@@ -0,0 +1,506 @@
+// Package sheet lays out songs as sheet music, to print them or draw in the game
+package sheet
+
+import (
+	"image"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+// For comparing lengths of notes, that are sums of fractions
+const epsilon = 1e-9
+
+// Sizes on the page, in spaces between the staff lines
+const (
+	margin       = 3.0
+	titleHeight  = 5.0
+	systemHeight = 11.0 // staff with some room for ledger lines
+	clefWidth    = 3.5
+	timeWidth    = 3.0
+)
+
+// Note or rest as it is written in the bar
+type item struct {
+	pitch      notes.Pitch // notes.Pause for rests
+	spelling   notes.Spelling
+	value      int // 1 for whole, 2 for half...
+	dotted     bool
+	start      float64 // from the start of the bar, in whole notes
+	tied       bool    // to the next note
+	continued  bool    // tied from the previous note
+	position   float64 // on the staff, in spaces from the bottom line
+	accidental int     // natural, sharp or flat, or -1 when not written
+	x          float64 // of the head, from the start of the bar, in spaces
+}
+
+func (it item) isRest() bool {
+	return it.pitch.Name == notes.Pause.Name
+}
+
+type bar struct {
+	items []item
+	width float64 // in spaces
+}
+
+// Line of the staff with bars on it
+type system struct {
+	bars    []bar
+	header  float64 // width of the clef and signatures
+	stretch float64 // of the bars, to fill the line
+}
+
+// Key and time signatures
+type signature struct {
+	fifths      int // of the key signature
+	beats, unit int // time signature
+}
+
+func songSignature(song config.Song) (sig signature, err error) {
+	if sig.fifths, err = song.Fifths(); err != nil {
+		return sig, err
+	}
+	sig.beats, sig.unit, err = song.TimeSignature()
+	return sig, err
+}
+
+// In whole notes
+func (sig signature) barLength() float64 {
+	return float64(sig.beats) / float64(sig.unit)
+}
+
+// Sheet is the song laid out on the page
+type Sheet struct {
+	signature
+	Title         string
+	Width, Height float64 // in pixels
+	space         float64 // between lines of the staff, in pixels
+	systems       []system
+}
+
+// New lays out the song on the page of the given width in pixels
+func New(song config.Song, width float64) (*Sheet, error) {
+	s := &Sheet{Title: song.Name, Width: width, space: 10}
+	var err error
+	if s.signature, err = songSignature(song); err != nil {
+		return nil, err
+	}
+	parsed, err := song.ParseNotes(1)
+	if err != nil {
+		return nil, err
+	}
+	bars := splitBars(parsed, s.barLength())
+	for i := range bars {
+		s.placeAccidentals(&bars[i])
+		spaceItems(&bars[i])
+	}
+	s.breakLines(bars)
+	s.Height = (margin*2 + titleHeight + systemHeight*float64(len(s.systems))) * s.space
+	return s, nil
+}
+
+// Length of the note in whole notes
+func length(value int, dotted bool) float64 {
+	l := 1 / float64(value)
+	if dotted {
+		l *= 1.5
+	}
+	return l
+}
+
+// Split notes into bars, notes that do not fit into the bar are tied with the notes of the next one.
+// Notes without written value are skipped.
+func splitBars(song []notes.SongNote, barLength float64) []bar {
+	var bars []bar
+	var current bar
+	at := 0.0 // from the start of the current bar
+	for _, n := range song {
+		if n.Value <= 0 {
+			continue
+		}
+		left := length(n.Value, n.Dotted)
+		continued := false
+		for left > epsilon {
+			if at > barLength-epsilon {
+				bars = append(bars, current)
+				current = bar{}
+				at = 0
+			}
+			part := math.Min(left, barLength-at)
+			values := []item{{value: n.Value, dotted: n.Dotted}}
+			if part < left-epsilon || continued {
+				values = writtenValues(part)
+			}
+			for i, v := range values {
+				v.pitch = n.Pitch
+				v.spelling = n.Spelling
+				v.start = at
+				v.continued = continued && !v.isRest()
+				v.tied = !v.isRest() && (i+1 < len(values) || part < left-epsilon)
+				current.items = append(current.items, v)
+				at += length(v.value, v.dotted)
+				continued = true
+			}
+			left -= part
+		}
+	}
+	if len(current.items) > 0 {
+		bars = append(bars, current)
+	}
+	return bars
+}
+
+// Notes that add up to the length, longest first
+func writtenValues(l float64) (result []item) {
+	for l > epsilon {
+		found := false
+		for v := 1; v <= 64 && !found; v *= 2 {
+			for _, dotted := range []bool{true, false} {
+				if length(v, dotted) <= l+epsilon {
+					result = append(result, item{value: v, dotted: dotted})
+					l -= length(v, dotted)
+					found = true
+					break
+				}
+			}
+		}
+		if !found { // shorter than anything we could write
+			return result
+		}
+	}
+	return result
+}
+
+// Alteration of the letter by the key signature
+func keyAlteration(letter byte, fifths int) int {
+	if fifths > 0 && strings.IndexByte("fcgdaeb", letter) < fifths {
+		return 1
+	}
+	if fifths < 0 && strings.IndexByte("beadgcf", letter) < -fifths {
+		return -1
+	}
+	return 0
+}
+
+// Find positions of the notes, and which accidentals should be written.
+// Accidental is valid until the end of the bar.
+func (sig signature) placeAccidentals(b *bar) {
+	altered := make(map[notes.Spelling]int) // natural notes altered by the accidentals in this bar
+	for i := range b.items {
+		it := &b.items[i]
+		it.accidental = -1
+		if it.isRest() {
+			it.position = 2
+			continue
+		}
+		sp := it.spelling
+		if sp.Letter == 0 {
+			sp = notes.Spell(it.pitch, sig.fifths)
+			it.spelling = sp
+		}
+		it.position = sp.Position()
+		current, ok := altered[sp.Natural()]
+		if !ok {
+			current = keyAlteration(sp.Letter, sig.fifths)
+		}
+		if sp.Alteration != current && !it.continued {
+			it.accidental = []int{doubleFlat, flat, natural, sharp, doubleSharp}[sp.Alteration+2]
+		}
+		altered[sp.Natural()] = sp.Alteration
+	}
+}
+
+// Room for the note, so longer notes take more space, but not proportionally
+func spacing(it item) float64 {
+	w := 1.6 + 7*math.Sqrt(length(it.value, it.dotted))
+	if it.dotted {
+		w += 0.5
+	}
+	return w
+}
+
+func spaceItems(b *bar) {
+	x := 1.5
+	for i := range b.items {
+		if b.items[i].accidental >= 0 {
+			x += accidentalWidth
+		}
+		b.items[i].x = x
+		x += spacing(b.items[i])
+	}
+	b.width = x
+}
+
+// Put bars on lines, and stretch them to fill the width of the page
+func (s *Sheet) breakLines(bars []bar) {
+	available := s.Width/s.space - margin*2
+	header := clefWidth + math.Abs(float64(s.fifths))
+	var current system
+	used := 0.0
+	for _, b := range bars {
+		if len(current.bars) > 0 && used+b.width > available {
+			current.stretch = (available - current.header) / (used - current.header)
+			s.systems = append(s.systems, current)
+			current = system{}
+		}
+		if len(current.bars) == 0 {
+			current.header = header
+			if len(s.systems) == 0 {
+				current.header += timeWidth
+			}
+			used = current.header
+		}
+		current.bars = append(current.bars, b)
+		used += b.width
+	}
+	if len(current.bars) > 0 {
+		current.stretch = (available - current.header) / (used - current.header)
+		if current.stretch > 1 && len(s.systems) > 0 { // last line is short, do not stretch it too much
+			current.stretch = math.Min(current.stretch, s.systems[len(s.systems)-1].stretch)
+		} else if current.stretch > 1.5 { // song with a single line
+			current.stretch = 1.5
+		}
+		s.systems = append(s.systems, current)
+	}
+}
+
+// Place of the note, where ties could start or end
+type headPlace struct {
+	system int
+	x      float64 // from the start of the staff, in spaces
+	it     item
+}
+
+// Draw the whole sheet
+func (s *Sheet) Draw(p Painter) {
+	p.Text(pixel.V(s.Width/2, s.Height-(margin+titleHeight/2)*s.space), 2.2*s.space, s.Title)
+	var heads []headPlace
+	for i, sys := range s.systems {
+		top := margin + titleHeight + systemHeight*float64(i) + 3 // room for notes above the staff
+		st := staff{p: p, origin: pixel.V(margin*s.space, s.Height-(top+4)*s.space), space: s.space}
+		end := sys.header
+		for _, b := range sys.bars {
+			end += b.width * sys.stretch
+		}
+		st.lines(0, end)
+		st.trebleClef(0.3)
+		x := clefWidth + st.keySignature(clefWidth-0.3, s.fifths)
+		if i == 0 {
+			st.timeSignature(x+timeWidth/2, s.beats, s.unit)
+			x += timeWidth
+		}
+		for j, b := range sys.bars {
+			items := append([]item(nil), b.items...)
+			for k := range items {
+				items[k].x = x + items[k].x*sys.stretch
+				heads = append(heads, headPlace{system: i, x: items[k].x, it: items[k]})
+			}
+			s.drawBar(st, items)
+			x += b.width * sys.stretch
+			if i+1 == len(s.systems) && j+1 == len(sys.bars) { // end of the song
+				st.line(0.1, pixel.V(x-0.6, 0), pixel.V(x-0.6, 4))
+				st.line(0.5, pixel.V(x-0.25, 0), pixel.V(x-0.25, 4))
+			} else {
+				st.line(0.1, pixel.V(x, 0), pixel.V(x, 4))
+			}
+		}
+	}
+	s.drawTies(p, heads)
+}
+
+// Ties from the notes to the next ones, or to the end of the line
+func (s *Sheet) drawTies(p Painter, heads []headPlace) {
+	for i, h := range heads {
+		if !h.it.tied || i+1 >= len(heads) {
+			continue
+		}
+		top := margin + titleHeight + systemHeight*float64(h.system) + 3
+		st := staff{p: p, origin: pixel.V(margin*s.space, s.Height-(top+4)*s.space), space: s.space}
+		endX := heads[i+1].x - 0.6
+		if heads[i+1].system != h.system {
+			endX = s.Width/s.space - margin*2
+		}
+		drawTie(st, h.it, endX)
+	}
+}
+
+// Arc from the note to x, on the side opposite to the stem
+func drawTie(st staff, it item, toX float64) {
+	side := -1.0
+	if it.position >= 2 {
+		side = 1
+	}
+	y := it.position + side*0.6
+	st.line(0.15, curve(6,
+		pixel.V(it.x+0.6, y),
+		pixel.V((it.x+toX)/2, y+side*0.6),
+		pixel.V(toX, y),
+	)...)
+}
+
+// Is the note drawn with the beam
+func beamable(it item) bool {
+	return !it.isRest() && it.value >= 8 && it.value&(it.value-1) == 0
+}
+
+// How many flags or beams the note has
+func flagCount(value int) int {
+	count := 0
+	for v := value; v >= 8; v /= 2 {
+		count++
+	}
+	return count
+}
+
+func (sig signature) beatLength() float64 {
+	if sig.unit >= 8 && sig.beats%3 == 0 && sig.beats > 3 { // compound time, like 6/8
+		return 3 / float64(sig.unit)
+	}
+	return 1 / float64(sig.unit)
+}
+
+// Draw notes of the bar, items have x relative to the staff
+func (sig signature) drawBar(st staff, items []item) {
+	beat := sig.beatLength()
+	for i := 0; i < len(items); {
+		it := items[i]
+		if it.isRest() {
+			st.rest(it.x, it.value)
+			if it.dotted {
+				st.dot(it.x, 2.5)
+			}
+			i++
+			continue
+		}
+		// notes in the same beat are beamed together
+		j := i + 1
+		if beamable(it) {
+			for j < len(items) && beamable(items[j]) &&
+				math.Floor(items[j].start/beat+epsilon) == math.Floor(it.start/beat+epsilon) {
+				j++
+			}
+		}
+		if j-i > 1 {
+			drawBeamed(st, items[i:j])
+		} else {
+			drawNote(st, it)
+		}
+		i = j
+	}
+}
+
+// Head, accidental and dot, without the stem
+func drawHead(st staff, it item) {
+	st.ledgerLines(it.x, it.position)
+	if it.accidental >= 0 {
+		st.accidental(it.x-accidentalWidth, it.position, it.accidental)
+	}
+	st.head(it.x, it.position, it.value)
+	if it.dotted {
+		st.dot(it.x, it.position)
+	}
+}
+
+func stemX(it item, up bool) float64 {
+	if up {
+		return it.x + 0.55
+	}
+	return it.x - 0.55
+}
+
+func drawNote(st staff, it item) {
+	drawHead(st, it)
+	if it.value < 2 {
+		return
+	}
+	up := it.position < 2
+	dir := 1.0
+	if !up {
+		dir = -1
+	}
+	end := pixel.V(stemX(it, up), it.position+dir*stemLength)
+	st.line(stemWidth, pixel.V(end.X, it.position), end)
+	if it.value >= 8 {
+		st.flags(end, up, flagCount(it.value))
+	}
+}
+
+func drawBeamed(st staff, items []item) {
+	average := 0.0
+	for _, it := range items {
+		average += it.position
+	}
+	up := average/float64(len(items)) < 2
+	dir := 1.0
+	if !up {
+		dir = -1
+	}
+	first, last := items[0], items[len(items)-1]
+	x1, x2 := stemX(first, up), stemX(last, up)
+	y1 := first.position + dir*stemLength
+	rise := math.Max(-1, math.Min(1, last.position-first.position))
+	beamY := func(x float64) float64 {
+		return y1 + rise*(x-x1)/(x2-x1)
+	}
+	// move beam away, so no stem is too short
+	shift := 0.0
+	for _, it := range items {
+		shift = math.Max(shift, dir*(it.position+dir*2.5-beamY(stemX(it, up))))
+	}
+	y1 += dir * shift
+
+	for _, it := range items {
+		drawHead(st, it)
+		x := stemX(it, up)
+		st.line(stemWidth, pixel.V(x, it.position), pixel.V(x, beamY(x)))
+	}
+	beam := func(level int, from, to float64) {
+		offset := -dir * 0.75 * float64(level)
+		st.fill(
+			pixel.V(from, beamY(from)+offset),
+			pixel.V(to, beamY(to)+offset),
+			pixel.V(to, beamY(to)+offset-dir*0.5),
+			pixel.V(from, beamY(from)+offset-dir*0.5),
+		)
+	}
+	beam(0, x1, x2)
+	for level := 1; level < 6; level++ {
+		for i, it := range items {
+			if flagCount(it.value) <= level {
+				continue
+			}
+			x := stemX(it, up)
+			switch {
+			case i+1 < len(items) && flagCount(items[i+1].value) > level:
+				beam(level, x, stemX(items[i+1], up))
+			case i > 0 && flagCount(items[i-1].value) > level:
+				// already joined with the previous one
+			case i+1 < len(items):
+				beam(level, x, x+1)
+			default:
+				beam(level, x-1, x)
+			}
+		}
+	}
+}
+
+// WriteSVG writes the sheet as SVG image
+func (s *Sheet) WriteSVG(w io.Writer) error {
+	p := &svgPainter{height: s.Height}
+	s.Draw(p)
+	return p.WriteTo(w, s.Width)
+}
+
+// Image of the sheet on white background
+func (s *Sheet) Image() *image.RGBA {
+	canvas := ui.NewOffscreen(pixel.R(0, 0, math.Ceil(s.Width), math.Ceil(s.Height)))
+	canvas.Clear(colornames.White)
+	s.Draw(NewCanvasPainter(canvas))
+	return canvas.Image()
+}
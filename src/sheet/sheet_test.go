@@ -0,0 +1,105 @@
+package sheet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitBars(t *testing.T) {
+	song, err := config.Song{Notes: "c2. d2 e4"}.ParseNotes(1)
+	assert.NoError(t, err)
+	bars := splitBars(song, 1)
+	assert.Len(t, bars, 2)
+
+	written := func(b bar) (result []item) {
+		for _, it := range b.items {
+			result = append(result, item{value: it.value, dotted: it.dotted, tied: it.tied, continued: it.continued})
+		}
+		return result
+	}
+	assert.Equal(t, []item{{value: 2, dotted: true}, {value: 4, tied: true}}, written(bars[0]))
+	assert.Equal(t, []item{{value: 4, continued: true}, {value: 4}}, written(bars[1]))
+}
+
+func TestAccidentals(t *testing.T) {
+	s, err := New(config.Song{Key: "g", Notes: "f fis f g gis bes b1"}, 800)
+	assert.NoError(t, err)
+	var accidentals []int
+	for _, b := range s.systems[0].bars {
+		for _, it := range b.items {
+			accidentals = append(accidentals, it.accidental)
+		}
+	}
+	// b1 does not fit into the bar, and is tied to the b2 without accidental
+	assert.Equal(t, []int{natural, sharp, natural, -1, sharp, flat, natural, -1}, accidentals)
+
+	s, err = New(config.Song{Key: "f", Notes: "bes b des' cis' e eis"}, 800)
+	assert.NoError(t, err)
+	accidentals = nil
+	for _, b := range s.systems[0].bars {
+		for _, it := range b.items {
+			accidentals = append(accidentals, it.accidental)
+		}
+	}
+	assert.Equal(t, []int{-1, natural, flat, sharp, -1, sharp}, accidentals)
+}
+
+func TestWriteSVG(t *testing.T) {
+	s, err := New(config.Song{Name: "Scale & arpeggio", Time: "3/4", Key: "d minor", Notes: "d8 e f g a bes c' d'4. p8 d16 f a d' d'2."}, 600)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, s.WriteSVG(&buf))
+
+	// should be valid XML
+	dec := xml.NewDecoder(&buf)
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			break
+		}
+	}
+}
+
+func TestWrongSignatures(t *testing.T) {
+	_, err := New(config.Song{Key: "h", Notes: "c"}, 800)
+	assert.Error(t, err)
+	_, err = New(config.Song{Time: "four", Notes: "c"}, 800)
+	assert.Error(t, err)
+}
+
+func TestScroll(t *testing.T) {
+	song := config.Song{Time: "3/4", Notes: "c d e f2."}
+	session, err := song.ParseNotes(2) // 120 bpm
+	assert.NoError(t, err)
+	session = append([]notes.SongNote{{Duration: 1.0, Time: -1.0, Pitch: notes.C}}, session...) // note to start the game
+	sc, err := NewScroll(song, session, 120)
+	assert.NoError(t, err)
+	assert.Equal(t, config.TimeBeforeFirstNote, sc.start)
+	assert.Len(t, sc.bars, 2)
+	assert.Len(t, sc.bars[0].items, 3)
+}
+
+func TestCommand(t *testing.T) {
+	songs := config.Songs
+	defer func() { config.Songs = songs }()
+	config.Songs = []config.Song{{Name: "Test", Notes: "c d e f"}}
+	path := filepath.Join(t.TempDir(), "test.svg")
+
+	var out bytes.Buffer
+	assert.NoError(t, Command([]string{"Test", path, "--width", "400"}, &out)) // flags after the arguments
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `width="400"`)
+	assert.Error(t, Command([]string{"--width", "400", "Test"}, &out), "no output file")
+}
@@ -0,0 +1,96 @@
+// Package spectrum finds how loud are different frequencies in the sound
+package spectrum
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FFT transforms signal to frequencies in place. Length should be a power of two.
+func FFT(x []complex128) {
+	n := len(x)
+	// reorder by bit reversed indexes, so it could be done without recursion
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
+
+// Analyzer keeps buffers to find spectrum of the same size again and again
+type Analyzer struct {
+	SampleRate float64
+	window     []float64
+	buf        []complex128
+	magnitudes []float64
+}
+
+// NewAnalyzer for blocks of samples of the given length. It is padded to the power of two with silence.
+func NewAnalyzer(sampleRate float64, length int) *Analyzer {
+	size := 1
+	for size < length {
+		size <<= 1
+	}
+	a := &Analyzer{
+		SampleRate: sampleRate,
+		window:     make([]float64, length),
+		buf:        make([]complex128, size),
+		magnitudes: make([]float64, size/2),
+	}
+	for i := range a.window { // Hann window, so the edges of the block do not add noise
+		a.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(length-1))
+	}
+	return a
+}
+
+// Spectrum of the left channel of the samples, of the length given to NewAnalyzer.
+// Magnitudes are for frequencies BinWidth apart, and are reused by the next call.
+func (a *Analyzer) Spectrum(samples [][2]float64) []float64 {
+	for i := range a.buf {
+		a.buf[i] = 0
+	}
+	for i, s := range samples {
+		if i >= len(a.window) {
+			break
+		}
+		a.buf[i] = complex(s[0]*a.window[i], 0)
+	}
+	FFT(a.buf)
+	scale := 4 / float64(len(a.window)) // so the full scale sine has magnitude 1
+	for i := range a.magnitudes {
+		a.magnitudes[i] = cmplx.Abs(a.buf[i]) * scale
+	}
+	return a.magnitudes
+}
+
+// Difference of frequencies of the neighbour magnitudes, in Hz
+func (a *Analyzer) BinWidth() float64 {
+	return a.SampleRate / float64(len(a.buf))
+}
+
+// Magnitude at the frequency, interpolated between the bins
+func (a *Analyzer) At(magnitudes []float64, frequency float64) float64 {
+	f := frequency / a.BinWidth()
+	i := int(f)
+	if i < 0 || i+1 >= len(magnitudes) {
+		return 0
+	}
+	t := f - float64(i)
+	return magnitudes[i]*(1-t) + magnitudes[i+1]*t
+}
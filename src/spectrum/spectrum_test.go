@@ -0,0 +1,45 @@
+package spectrum
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFT(t *testing.T) {
+	x := make([]complex128, 8)
+	for i := range x {
+		x[i] = complex(math.Cos(2*math.Pi*2*float64(i)/8), 0) // two periods
+	}
+	FFT(x)
+	for i, v := range x {
+		expected := 0.0
+		if i == 2 || i == 6 {
+			expected = 4
+		}
+		assert.InDelta(t, expected, real(v), 1e-9, "bin %d", i)
+		assert.InDelta(t, 0, imag(v), 1e-9, "bin %d", i)
+	}
+}
+
+func TestSpectrum(t *testing.T) {
+	const rate = 44100.0
+	samples := make([][2]float64, 3000)
+	for i := range samples {
+		samples[i][0] = 0.5 * math.Sin(2*math.Pi*880*float64(i)/rate)
+	}
+	a := NewAnalyzer(rate, len(samples))
+	assert.InDelta(t, rate/4096, a.BinWidth(), 1e-9)
+	m := a.Spectrum(samples)
+
+	loudest := 0
+	for i := range m {
+		if m[i] > m[loudest] {
+			loudest = i
+		}
+	}
+	assert.InDelta(t, 880, float64(loudest)*a.BinWidth(), a.BinWidth())
+	assert.InDelta(t, 0.5, a.At(m, 880), 0.1)
+	assert.True(t, a.At(m, 1500) < 0.01, "quiet far from the tone")
+}
@@ -0,0 +1,74 @@
+package transcribe
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Notes shorter than that are glitches of pitch detection, in seconds
+const DefaultMinDuration = 0.06
+
+// Command transcribes WAV file given in args, and writes the result to out.
+// Used as "fasolasi transcribe input.wav --bpm 90".
+func Command(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	bpm := fs.Int("bpm", 90, "tempo of the recording, in beats per minute")
+	grid := fs.Int("grid", 8, "shortest note to write down: 4, 8, 16...")
+	minDuration := fs.Float64("min", DefaultMinDuration, "notes shorter than that are ignored, in seconds")
+	format := fs.String("format", "notes", "output format: notes or midi")
+	output := fs.String("o", "", "file to write to, instead of the standard output")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: fasolasi transcribe [options] input.wav")
+		fs.PrintDefaults()
+	}
+
+	// flags could go before or after the file name
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one input file, got %d", len(files))
+	}
+	if *bpm <= 0 || *grid <= 0 || *grid&(*grid-1) != 0 {
+		return fmt.Errorf("bpm should be positive, and grid should be a power of 2")
+	}
+	if *format != "notes" && *format != "midi" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	samples, rate, err := ReadWAV(files[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", files[0], err)
+	}
+	played := Segment(Detect(samples, rate), *minDuration)
+	if len(played) == 0 {
+		return fmt.Errorf("no notes found in %s", files[0])
+	}
+	written := Quantize(played, played[0].Start, *bpm, *grid)
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "midi" {
+		return WriteMIDI(out, written, *bpm)
+	}
+	_, err = fmt.Fprintln(out, Notation(written))
+	return err
+}
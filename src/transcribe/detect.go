@@ -0,0 +1,46 @@
+package transcribe
+
+import (
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/bunyk/fasolasi/src/yin"
+	"github.com/unixpickle/wav"
+)
+
+// Length of the sound analyzed for every event, in seconds.
+// Should contain several periods of the lowest note.
+const frameLength = 0.05
+
+// Probability below which yin result is considered a noise
+const yinThreshold = 0.05
+
+// ReadWAV returns samples of the file, mixed to mono, and its sample rate
+func ReadWAV(path string) ([]float64, int, error) {
+	s, err := wav.ReadSoundFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	channels := s.Channels()
+	samples := make([]float64, len(s.Samples())/channels)
+	for i, v := range s.Samples() {
+		samples[i/channels] += float64(v) / float64(channels)
+	}
+	return samples, s.SampleRate(), nil
+}
+
+// Detect pitch in the sound, every half of the frame
+func Detect(samples []float64, sampleRate int) []Event {
+	size := int(frameLength * float64(sampleRate))
+	hop := size / 2
+	detector := yin.NewYin(float64(sampleRate), size, yinThreshold)
+	var events []Event
+	for start := 0; start+size <= len(samples); start += hop {
+		frequency := detector.GetPitch(samples[start : start+size])
+		detector.Clean()
+		pitch, _ := notes.GuessNote(frequency)
+		events = append(events, Event{
+			Time:  float64(start) / float64(sampleRate),
+			Pitch: pitch,
+		})
+	}
+	return events
+}
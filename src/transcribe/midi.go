@@ -0,0 +1,56 @@
+package transcribe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+const ticksPerQuarter = 480
+
+// WriteMIDI writes notes as a standard MIDI file with one track
+func WriteMIDI(w io.Writer, written []Written, bpm int) error {
+	var track bytes.Buffer
+	// tempo, in microseconds per quarter note
+	tempo := 60000000 / bpm
+	track.Write([]byte{0, 0xFF, 0x51, 3, byte(tempo >> 16), byte(tempo >> 8), byte(tempo)})
+	// recorder program
+	track.Write([]byte{0, 0xC0, 74})
+
+	delay := 0 // ticks since the last event
+	for _, n := range written {
+		ticks := int(math.Round(n.Length() * 4 * ticksPerQuarter))
+		if n.Pitch.Frequency < 0 {
+			delay += ticks
+			continue
+		}
+		key := byte(math.Round(69 + 12*math.Log2(n.Pitch.Frequency/440)))
+		writeVarInt(&track, delay)
+		track.Write([]byte{0x90, key, 100})
+		writeVarInt(&track, ticks)
+		track.Write([]byte{0x80, key, 0})
+		delay = 0
+	}
+	writeVarInt(&track, delay)
+	track.Write([]byte{0xFF, 0x2F, 0}) // end of track
+
+	var file bytes.Buffer
+	file.WriteString("MThd")
+	binary.Write(&file, binary.BigEndian, []uint32{6})
+	binary.Write(&file, binary.BigEndian, []uint16{0, 1, ticksPerQuarter})
+	file.WriteString("MTrk")
+	binary.Write(&file, binary.BigEndian, uint32(track.Len()))
+	file.Write(track.Bytes())
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Numbers in MIDI events are written 7 bits per byte, highest bit means that more bytes follow
+func writeVarInt(buf *bytes.Buffer, v int) {
+	b := []byte{byte(v & 0x7F)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7F) | 0x80}, b...)
+	}
+	buf.Write(b)
+}
@@ -0,0 +1,154 @@
+// Package transcribe writes down notes played, in the notation used for songs
+package transcribe
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bunyk/fasolasi/src/notes"
+)
+
+// Event is a pitch heard at some moment
+type Event struct {
+	Time  float64 // in seconds
+	Pitch notes.Pitch
+}
+
+// Note is a pitch heard for some time
+type Note struct {
+	Pitch      notes.Pitch
+	Start, End float64 // in seconds
+}
+
+// Segment groups events with the same pitch into notes.
+// Each event lasts until the next one, last event has no duration.
+// Notes shorter than minDuration are treated as glitches of pitch detection and dropped,
+// notes of the same pitch around them are joined.
+func Segment(events []Event, minDuration float64) []Note {
+	var runs []Note
+	for i, e := range events {
+		if i+1 == len(events) {
+			break
+		}
+		if len(runs) > 0 && runs[len(runs)-1].Pitch.Name == e.Pitch.Name {
+			runs[len(runs)-1].End = events[i+1].Time
+			continue
+		}
+		runs = append(runs, Note{Pitch: e.Pitch, Start: e.Time, End: events[i+1].Time})
+	}
+
+	var result []Note
+	for _, r := range runs {
+		if r.Pitch.Name == "p" || r.End-r.Start < minDuration {
+			continue
+		}
+		if len(result) > 0 {
+			last := &result[len(result)-1]
+			if last.Pitch.Name == r.Pitch.Name && r.Start-last.End < minDuration {
+				last.End = r.End
+				continue
+			}
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// Written is a note as it is written in the song
+type Written struct {
+	Pitch  notes.Pitch
+	Value  int // 1 for whole note, 2 for half, 4 for quarter...
+	Dotted bool
+}
+
+// Length in whole notes
+func (w Written) Length() float64 {
+	l := 1.0 / float64(w.Value)
+	if w.Dotted {
+		l *= 1.5
+	}
+	return l
+}
+
+// Quantize aligns notes to the grid, where grid is the shortest note to write: 8 for eighths, 16 for sixteenths...
+// Grid starts at origin, in seconds. Pass start of the first note if there was no metronome to play along.
+// Notes that do not fit into written durations are split into several ones of the same pitch,
+// and gaps between notes longer than the grid step become pauses. Pause before the first note is not written.
+func Quantize(played []Note, origin float64, bpm, grid int) []Written {
+	if len(played) == 0 {
+		return nil
+	}
+	step := 240.0 / float64(bpm) / float64(grid) // in seconds
+	at := func(t float64) int {
+		return int(math.Round((t - origin) / step))
+	}
+
+	var result []Written
+	position := at(played[0].Start) // end of the last written note, in steps
+	for i, n := range played {
+		start := at(n.Start)
+		end := at(n.End)
+		if i+1 < len(played) && played[i+1].Start-n.End < step {
+			end = at(played[i+1].Start) // player just took a breath before the next note
+		}
+		if start < position { // previous note was rounded over this one
+			start = position
+		}
+		if end <= start {
+			end = start + 1
+		}
+		result = append(result, split(notes.Pause, start-position, grid)...)
+		result = append(result, split(n.Pitch, end-start, grid)...)
+		position = end
+	}
+	return result
+}
+
+// Write steps of the grid as a sequence of notes, longest first
+func split(pitch notes.Pitch, steps, grid int) (result []Written) {
+	for steps > 0 {
+		for value := 1; value <= grid; value *= 2 {
+			whole := grid / value // steps in this note
+			if whole*3%2 == 0 && whole*3/2 <= steps && value < grid {
+				result = append(result, Written{pitch, value, true})
+				steps -= whole * 3 / 2
+				break
+			}
+			if whole <= steps {
+				result = append(result, Written{pitch, value, false})
+				steps -= whole
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Notation of the notes, one line for every bar of 4/4.
+// Duration is written only when it changes, like in the songs written by hand.
+func Notation(written []Written) string {
+	var sb strings.Builder
+	lastValue, lastDotted := 4, false // default duration of the first note
+	bar := 0.0
+	for i, w := range written {
+		if i > 0 {
+			if bar >= 1 {
+				sb.WriteString("\n")
+				bar = math.Mod(bar, 1)
+			} else {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(w.Pitch.Name)
+		if w.Value != lastValue || w.Dotted != lastDotted {
+			fmt.Fprint(&sb, w.Value)
+			if w.Dotted {
+				sb.WriteString(".")
+			}
+		}
+		lastValue, lastDotted = w.Value, w.Dotted
+		bar += w.Length()
+	}
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package transcribe
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscribeFile(t *testing.T) {
+	var out bytes.Buffer
+	err := Command([]string{"../yin/test.wav", "--bpm", "60", "--grid", "16"}, &out)
+	assert.NoError(t, err)
+	// test.wav is a silence, then g, a and b
+	assert.Equal(t, "g16 a b8.\n", out.String())
+}
+
+func TestSegmentAndQuantize(t *testing.T) {
+	var events []Event
+	tm := 1.0
+	for _, part := range []struct {
+		pitch    string
+		duration float64
+	}{
+		{"c", 0.6},
+		{"p", 0.05}, // breath
+		{"d", 0.3},
+		{"e", 0.02}, // glitch
+		{"d", 0.28},
+		{"p", 0.6},
+		{"g", 1.2},
+		{"p", 0.1},
+	} {
+		for dt := 0.0; dt < part.duration; dt += 1.0 / 60 {
+			events = append(events, Event{tm + dt, notes.PitchByName[part.pitch]})
+		}
+		tm += part.duration
+	}
+
+	played := Segment(events, DefaultMinDuration)
+	assert.Len(t, played, 3)
+	// at 100 bpm quarter note is 0.6 seconds
+	written := Quantize(played, played[0].Start, 100, 8)
+	assert.Equal(t, "c d p g2", Notation(written))
+}
+
+func TestNotationBars(t *testing.T) {
+	c, d := notes.PitchByName["c"], notes.PitchByName["d"]
+	written := []Written{{c, 2, true}, {d, 4, false}, {c, 8, false}, {d, 8, false}, {c, 2, false}, {d, 4, false}}
+	assert.Equal(t, "c2. d4\nc8 d c2 d4", Notation(written))
+}
+
+func TestSplit(t *testing.T) {
+	c := notes.PitchByName["c"]
+	assert.Equal(t, []Written{{c, 1, false}, {c, 4, true}}, split(c, 11, 8))
+	assert.Equal(t, []Written{{c, 16, false}}, split(c, 1, 16))
+}
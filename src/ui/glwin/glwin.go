@@ -0,0 +1,92 @@
+// Package glwin adapts OpenGL window of pixelgl to ui.Window
+package glwin
+
+import (
+	"image"
+	"image/png"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/bunyk/fasolasi/src/ui"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Window saves screenshot when F12 is pressed, and gamepad buttons work as keys
+type Window struct {
+	*pixelgl.Window
+}
+
+func New(w *pixelgl.Window) *Window {
+	return &Window{Window: w}
+}
+
+// Keys pressed by the buttons of gamepads
+var gamepadKeys = map[ui.Key][]pixelgl.GamepadButton{
+	ui.KeyUp:     {pixelgl.ButtonDpadUp},
+	ui.KeyDown:   {pixelgl.ButtonDpadDown},
+	ui.KeyLeft:   {pixelgl.ButtonDpadLeft},
+	ui.KeyRight:  {pixelgl.ButtonDpadRight},
+	ui.KeyEnter:  {pixelgl.ButtonA},
+	ui.KeyEscape: {pixelgl.ButtonB, pixelgl.ButtonBack},
+	ui.KeySpace:  {pixelgl.ButtonStart},
+}
+
+// Tells if any of the gamepads has the button for the key in the state
+func (w *Window) gamepad(k ui.Key, state func(pixelgl.Joystick, pixelgl.GamepadButton) bool) bool {
+	for js := pixelgl.Joystick1; js <= pixelgl.JoystickLast; js++ {
+		if !w.JoystickPresent(js) {
+			continue
+		}
+		for _, b := range gamepadKeys[k] {
+			if state(js, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *Window) Pressed(k ui.Key) bool {
+	return w.Window.Pressed(pixelgl.Button(k)) || w.gamepad(k, w.JoystickPressed)
+}
+
+func (w *Window) JustPressed(k ui.Key) bool {
+	return w.Window.JustPressed(pixelgl.Button(k)) || w.gamepad(k, w.JoystickJustPressed)
+}
+
+func (w *Window) Repeated(k ui.Key) bool {
+	return w.Window.Repeated(pixelgl.Button(k))
+}
+
+func (w *Window) Update() {
+	if w.JustPressed(ui.KeyF12) { // frame is still in the canvas until update
+		name := time.Now().Format("screenshot 2006-01-02 15-04-05.png")
+		if err := w.saveScreenshot(name); err != nil {
+			slog.Error("Failed to save screenshot", "err", err)
+		} else {
+			slog.Info("Saved screenshot", "file", name)
+		}
+	}
+	w.Window.Update()
+}
+
+func (w *Window) saveScreenshot(path string) error {
+	canvas := w.Canvas()
+	bounds := canvas.Bounds()
+	width, height := int(bounds.W()), int(bounds.H())
+	pixels := canvas.Pixels()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ { // OpenGL rows go from the bottom
+		copy(img.Pix[y*img.Stride:(y+1)*img.Stride], pixels[(height-1-y)*width*4:(height-y)*width*4])
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/bunyk/fasolasi/src/config"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
-	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
 )
 
@@ -17,6 +16,12 @@ type UiState struct {
 	hotitem    int
 	activeitem int
 	maxitem    int
+	kbditem    int  // widget with keyboard focus, 0 when the next one drawn could take it, -1 for the last one
+	kbdseen    bool // widget with keyboard focus was drawn this frame
+	kbdhandled bool // keys of this frame already moved focus or activated something
+	kbdforce   bool // next widget drawn takes focus
+	kbdused    bool // keyboard was used after the last click, so focus is shown
+	lastwidget int  // last widget that could have focus
 }
 
 var uistate = UiState{}
@@ -26,11 +31,15 @@ var imd = imdraw.New(nil)
 func Prepare() {
 	uistate.hotitem = 0
 	uistate.maxitem = 0
+	uistate.kbdseen = false
+	uistate.kbdhandled = false
+	uistate.lastwidget = 0
+	prepareVoice()
 }
 
 // Finish up after IMGUI code, end frame
-func Finish(win *pixelgl.Window) {
-	if win.Pressed(pixelgl.MouseButtonLeft) {
+func Finish(win Window) {
+	if win.Pressed(MouseButtonLeft) {
 		if uistate.activeitem == 0 {
 			// If the mouse is clicked, but no widget is active, we need to mark the active item unavailable so that we won't activate the next widget we drag the cursor onto.
 			uistate.activeitem = -1
@@ -39,15 +48,111 @@ func Finish(win *pixelgl.Window) {
 		// If mouse isn't down, we need to clear the active item in order not to make the widgets confused on the active state (and to enable the next clicked widget to become active).
 		uistate.activeitem = 0
 	}
+	if win.JustPressed(MouseButtonLeft) {
+		uistate.kbdused = false
+	}
+	if uistate.kbditem == -1 {
+		// Focus went back from the first widget, so it wraps to the last one
+		uistate.kbditem = uistate.lastwidget
+	} else if !uistate.kbdseen {
+		// Widget with focus is gone, so next one could take it
+		uistate.kbditem = 0
+	}
 	win.Update()
 }
 
+// ResetFocus gives keyboard focus to the first widget, for example when the scene is changed
+func ResetFocus() {
+	uistate.kbditem = 0
+	uistate.kbdforce = false
+	IgnoreVoice() // note that pressed something in the previous scene should not press anything in the new one
+}
+
+// Focus the next widget drawn
+func Focus() {
+	uistate.kbdforce = true
+}
+
+// Focused tells if the last widget drawn has keyboard focus
+func Focused() bool {
+	return uistate.lastwidget != 0 && uistate.kbditem == uistate.lastwidget
+}
+
+// Hovered tells if the mouse is over the last widget drawn
+func Hovered() bool {
+	return uistate.lastwidget != 0 && uistate.hotitem == uistate.lastwidget
+}
+
+// ConsumeKeys of this frame, when the scene handles them itself, so they don't move focus. Call it after Prepare.
+func ConsumeKeys() {
+	uistate.kbdhandled = true
+}
+
+// Typed key, when it is pressed or repeated while held
+func typed(win Window, k Key) bool {
+	return win.JustPressed(k) || win.Repeated(k)
+}
+
+// Keyboard focus of the widget: it is taken by the first widget, or on click, and moved by arrows and Tab.
+// With sideways, left and right arrows also move it, otherwise widget uses them for itself.
+func focus(win Window, id int, sideways bool) bool {
+	if uistate.kbditem == 0 || uistate.kbdforce || uistate.activeitem == id {
+		uistate.kbditem = id
+		uistate.kbdforce = false
+	}
+	focused := uistate.kbditem == id
+	if focused {
+		uistate.kbdseen = true
+		if !uistate.kbdhandled {
+			shift := win.Pressed(KeyLeftShift) || win.Pressed(KeyRightShift)
+			back := typed(win, KeyUp) || shift && typed(win, KeyTab) || sideways && typed(win, KeyLeft)
+			forward := typed(win, KeyDown) || !shift && typed(win, KeyTab) || sideways && typed(win, KeyRight)
+			if back {
+				uistate.kbditem = uistate.lastwidget
+				if uistate.kbditem == 0 {
+					uistate.kbditem = -1
+				}
+			} else if forward {
+				uistate.kbditem = 0
+			}
+			if back || forward {
+				uistate.kbdhandled = true
+				uistate.kbdused = true
+			}
+		}
+	}
+	uistate.lastwidget = id
+	return focused
+}
+
+// Enter pressed on the focused widget
+func activated(win Window, id int) bool {
+	if uistate.kbditem != id || uistate.kbdhandled || !win.JustPressed(KeyEnter) {
+		return false
+	}
+	uistate.kbdhandled = true
+	uistate.kbdused = true
+	return true
+}
+
+// Ring around the focused widget, when keyboard is used
+func focusRing(win Window, id int, location pixel.Rect) {
+	if uistate.kbditem != id || !uistate.kbdused {
+		return
+	}
+	imd.Clear()
+	imd.Color = config.FocusColor
+	imd.Push(location.Min.Sub(pixel.V(5, 5)), location.Max.Add(pixel.V(5, 5)))
+	imd.Rectangle(3)
+	imd.Draw(win)
+}
+
 func nextID() int {
 	uistate.maxitem++
 	return uistate.maxitem
 }
 
-func Label(win *pixelgl.Window, location pixel.Rect, value string, color color.Color) {
+func Label(win Canvas, location pixel.Rect, value string, color color.Color) {
 	txt := text.New(location.Center(), TextAtlas)
 	txt.Color = color
 	txt.Dot.X -= txt.BoundsOf(value).W() / 2
@@ -56,11 +161,21 @@ func Label(win *pixelgl.Window, location pixel.Rect, value string, color color.C
 	txt.Draw(win, pixel.IM)
 }
 
-func Button(win *pixelgl.Window, location pixel.Rect, label string) bool {
+// Button is clicked, activated with Enter when it has focus, or pressed by playing its note with voice control
+func Button(win Window, location pixel.Rect, label string) bool {
 	id := nextID()
+	focus(win, id, true)
+	clicked := button(win, id, location, label)
+	focusRing(win, id, location)
+	played := voiced(win, voiceNote(), location, false)
+	return activated(win, id) || clicked || played
+}
+
+// Button for the mouse only
+func button(win Window, id int, location pixel.Rect, label string) bool {
 	if location.Contains(win.MousePosition()) {
 		uistate.hotitem = id
-		if uistate.activeitem == 0 && win.Pressed(pixelgl.MouseButtonLeft) {
+		if uistate.activeitem == 0 && win.Pressed(MouseButtonLeft) {
 			uistate.activeitem = id
 		}
 	}
@@ -95,7 +210,7 @@ func Button(win *pixelgl.Window, location pixel.Rect, label string) bool {
 
 	// If button is hot and active, but mouse button is not
 	// down, the user must have clicked the button.
-	if !win.Pressed(pixelgl.MouseButtonLeft) &&
+	if !win.Pressed(MouseButtonLeft) &&
 		uistate.hotitem == id &&
 		uistate.activeitem == id {
 		return true
@@ -105,8 +220,87 @@ func Button(win *pixelgl.Window, location pixel.Rect, label string) bool {
 	return false
 }
 
+// Label with "-" and "+" buttons on the sides. When it has focus, left and right arrows work as them,
+// and with voice control they have notes on their sides.
+// Returns -1 or 1 when one of the buttons is clicked, 0 otherwise.
+func Spinner(win Window, location pixel.Rect, label string) int {
+	id := nextID()
+	focused := focus(win, id, false)
+	side := location.H()
+	change := 0
+	minus, plus := nextID(), nextID()
+	// voice notes are taken every frame, even when buttons are clicked, so the next buttons keep theirs
+	minusPlayed, plusPlayed := voiced(win, voiceNote(), location, false), voiced(win, voiceNote(), location, true)
+	if button(win, minus, pixel.R(location.Min.X, location.Min.Y, location.Min.X+side, location.Max.Y), "-") || minusPlayed {
+		change = -1
+	}
+	if button(win, plus, pixel.R(location.Max.X-side, location.Min.Y, location.Max.X, location.Max.Y), "+") || plusPlayed {
+		change = 1
+	}
+	if uistate.activeitem == minus || uistate.activeitem == plus {
+		uistate.kbditem = id
+	}
+	if focused && !uistate.kbdhandled {
+		if typed(win, KeyLeft) {
+			change = -1
+		}
+		if typed(win, KeyRight) {
+			change = 1
+		}
+		if change != 0 {
+			uistate.kbdhandled = true
+			uistate.kbdused = true
+		}
+	}
+	Label(win, location, label, config.ButtonTextColor)
+	focusRing(win, id, location)
+	return change
+}
+
+// Single line text input. Gets keyboard focus when clicked, or like other widgets.
+// Returns true when Enter is pressed in it.
+func TextField(win Window, location pixel.Rect, value *string) bool {
+	id := nextID()
+	if location.Contains(win.MousePosition()) {
+		uistate.hotitem = id
+		if uistate.activeitem == 0 && win.Pressed(MouseButtonLeft) {
+			uistate.activeitem = id
+		}
+	}
+	focused := focus(win, id, false)
+	if focused {
+		*value += win.Typed()
+		if win.JustPressed(KeyBackspace) || win.Repeated(KeyBackspace) {
+			runes := []rune(*value)
+			if len(runes) > 0 {
+				*value = string(runes[:len(runes)-1])
+			}
+		}
+	}
+
+	imd.Clear()
+	imd.Color = config.ButtonColor
+	if focused {
+		imd.Color = config.SelectionColor
+	}
+	imd.Push(location.Min, location.Max)
+	imd.Rectangle(0)
+	imd.Draw(win)
+
+	txt := text.New(pixel.V(location.Min.X+10, location.Center().Y), TextAtlas)
+	txt.Color = config.ButtonTextColor
+	txt.Dot.Y -= txt.LineHeight/2 - 5
+	fmt.Fprint(txt, *value)
+	if focused {
+		fmt.Fprint(txt, "|")
+	}
+	txt.Draw(win, pixel.IM)
+
+	return activated(win, id)
+}
+
 /*
-func slider(win *pixelgl.Window, location pixel.Rect, max int, value *int) bool {
+func slider(win Window, location pixel.Rect, max int, value *int) bool {
   // Check for hotness
   if location.Contains(win.MousePosition()) {
     uistate.hotitem = id;
@@ -117,7 +311,7 @@ func slider(win *pixelgl.Window, location pixel.Rect, max int, value *int) bool
 }
 */
 
-func Menu(win *pixelgl.Window, location pixel.Rect, items []string) int {
+func Menu(win Window, location pixel.Rect, items []string) int {
 	fl := FlexRows(location, config.MenuButtonWidth, config.MenuButtonHeight, config.MenuVerticalSpacing, len(items))
 
 	clicked := -1
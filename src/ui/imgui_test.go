@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/faiface/pixel"
+	"github.com/stretchr/testify/assert"
+)
+
+// Window where the test presses keys, one frame at a time
+type keysWindow struct {
+	*Offscreen
+	pressed map[Key]bool
+	mouse   *pixel.Vec // outside of the window when nil
+}
+
+func (w *keysWindow) Pressed(k Key) bool     { return w.pressed[k] }
+func (w *keysWindow) JustPressed(k Key) bool { return w.pressed[k] }
+
+func (w *keysWindow) MousePosition() pixel.Vec {
+	if w.mouse == nil {
+		return w.Offscreen.MousePosition()
+	}
+	return *w.mouse
+}
+
+// Frame with the menu of three buttons and a spinner, returns what was clicked and the change of the spinner
+func (w *keysWindow) frame(keys ...Key) (int, int) {
+	w.pressed = make(map[Key]bool)
+	for _, k := range keys {
+		w.pressed[k] = true
+	}
+	Prepare()
+	clicked := -1
+	for i := 0; i < 3; i++ {
+		if Button(w, pixel.R(0, float64(i*60), 100, float64(i*60+50)), "button") {
+			clicked = i
+		}
+	}
+	change := Spinner(w, pixel.R(0, 180, 100, 230), "spinner")
+	Finish(w)
+	return clicked, change
+}
+
+func TestFocus(t *testing.T) {
+	ResetFocus()
+	win := &keysWindow{Offscreen: NewOffscreen(pixel.R(0, 0, 100, 300))}
+	clicked, _ := win.frame(KeyEnter)
+	assert.Equal(t, 0, clicked, "first button has focus")
+
+	win.frame(KeyDown)
+	clicked, _ = win.frame(KeyEnter)
+	assert.Equal(t, 1, clicked)
+
+	win.frame(KeyRight)
+	win.frame(KeyTab)
+	_, change := win.frame(KeyRight)
+	assert.Equal(t, 1, change, "spinner uses arrows")
+	_, change = win.frame(KeyLeft)
+	assert.Equal(t, -1, change)
+
+	win.frame(KeyDown) // wraps to the first one
+	clicked, _ = win.frame(KeyEnter)
+	assert.Equal(t, 0, clicked)
+
+	win.frame(KeyUp) // and back to the last
+	_, change = win.frame(KeyRight)
+	assert.Equal(t, 1, change)
+
+	win.frame(KeyLeftShift, KeyTab)
+	clicked, _ = win.frame(KeyEnter)
+	assert.Equal(t, 2, clicked)
+
+	ResetFocus()
+	clicked, _ = win.frame(KeyEnter)
+	assert.Equal(t, 0, clicked)
+}
+
+func TestVoice(t *testing.T) {
+	heard := -1.0
+	SetVoice(func() float64 { return heard })
+	defer SetVoice(nil)
+	clock := time.Now()
+	voice.now = func() time.Time { return clock }
+	win := &keysWindow{Offscreen: NewOffscreen(pixel.R(0, 0, 100, 300))}
+
+	win.frame()
+	heard = notes.PitchByName["a"].Frequency // note of the second button
+	clicked, _ := win.frame()
+	assert.Equal(t, -1, clicked, "note should be held for a while")
+	clock = clock.Add(config.VoiceHoldTime)
+	clicked, _ = win.frame()
+	assert.Equal(t, 1, clicked)
+	clicked, _ = win.frame()
+	assert.Equal(t, -1, clicked, "pressed only once")
+
+	heard = notes.PitchByName["d'"].Frequency // "+" of the spinner
+	win.frame()
+	clock = clock.Add(config.VoiceHoldTime)
+	_, change := win.frame()
+	assert.Equal(t, 1, change)
+
+	heard = notes.PitchByName["b"].Frequency
+	win.frame()
+	ResetFocus() // new scene
+	clock = clock.Add(config.VoiceHoldTime)
+	clicked, _ = win.frame()
+	assert.Equal(t, -1, clicked, "note started in the previous scene")
+}
+
+func TestVoiceWithClick(t *testing.T) {
+	heard := -1.0
+	SetVoice(func() float64 { return heard })
+	defer SetVoice(nil)
+	clock := time.Now()
+	voice.now = func() time.Time { return clock }
+	win := &keysWindow{Offscreen: NewOffscreen(pixel.R(0, 0, 100, 300))}
+	frame := func() (int, bool) { // spinner has the first two notes, and button the third one
+		Prepare()
+		defer Finish(win)
+		return Spinner(win, pixel.R(0, 0, 100, 50), "spinner"), Button(win, pixel.R(0, 60, 100, 110), "button")
+	}
+
+	frame()
+	heard = notes.PitchByName[VoiceNotes[2]].Frequency
+	win.mouse, win.pressed = &pixel.Vec{X: 10, Y: 25}, map[Key]bool{MouseButtonLeft: true} // on "-"
+	frame()
+	clock = clock.Add(config.VoiceHoldTime)
+	win.pressed = nil // released
+	change, pressed := frame()
+	assert.Equal(t, -1, change)
+	assert.True(t, pressed, "button keeps its note while the spinner is clicked")
+}
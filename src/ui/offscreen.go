@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+
+	"github.com/faiface/pixel"
+)
+
+// Offscreen is a window without a screen. Scenes are drawn into image in memory,
+// by the software rasterizer, so it works on machines without OpenGL.
+// It gets no input.
+type Offscreen struct {
+	img    *image.RGBA
+	bounds pixel.Rect
+}
+
+func NewOffscreen(bounds pixel.Rect) *Offscreen {
+	return &Offscreen{
+		img:    image.NewRGBA(image.Rect(0, 0, int(bounds.W()), int(bounds.H()))),
+		bounds: bounds,
+	}
+}
+
+// Image drawn so far
+func (o *Offscreen) Image() *image.RGBA {
+	return o.img
+}
+
+func (o *Offscreen) SavePNG(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, o.img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (o *Offscreen) Bounds() pixel.Rect {
+	return o.bounds
+}
+
+func (o *Offscreen) Clear(c color.Color) {
+	r, g, b, a := c.RGBA()
+	fill := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+	for i := 0; i < len(o.img.Pix); i += 4 {
+		o.img.Pix[i], o.img.Pix[i+1], o.img.Pix[i+2], o.img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+}
+
+func (o *Offscreen) MakeTriangles(t pixel.Triangles) pixel.TargetTriangles {
+	tri := &offscreenTriangles{TrianglesData: pixel.MakeTrianglesData(t.Len()), dst: o}
+	tri.Update(t)
+	return tri
+}
+
+func (o *Offscreen) MakePicture(p pixel.Picture) pixel.TargetPicture {
+	if pd, ok := p.(*pixel.PictureData); ok {
+		return &offscreenPicture{PictureData: pd, dst: o}
+	}
+	return &offscreenPicture{PictureData: pixel.PictureDataFromPicture(p), dst: o}
+}
+
+func (o *Offscreen) MousePosition() pixel.Vec { return pixel.V(-1, -1) }
+func (o *Offscreen) MouseScroll() pixel.Vec   { return pixel.ZV }
+func (o *Offscreen) Pressed(k Key) bool       { return false }
+func (o *Offscreen) JustPressed(k Key) bool   { return false }
+func (o *Offscreen) Repeated(k Key) bool      { return false }
+func (o *Offscreen) Typed() string            { return "" }
+func (o *Offscreen) Update()                  {}
+func (o *Offscreen) SetClosed(closed bool)    {}
+
+type offscreenTriangles struct {
+	*pixel.TrianglesData
+	dst *Offscreen
+}
+
+func (t *offscreenTriangles) Draw() {
+	t.dst.fill(t.TrianglesData, nil)
+}
+
+type offscreenPicture struct {
+	*pixel.PictureData
+	dst *Offscreen
+}
+
+func (p *offscreenPicture) Draw(t pixel.TargetTriangles) {
+	if ot, ok := t.(*offscreenTriangles); ok {
+		p.dst.fill(ot.TrianglesData, p.PictureData)
+	}
+}
+
+// Rasterize triangles, coloring pixels with centers inside them.
+// With the picture, its color is taken at interpolated picture coordinates, and mixed in by intensity.
+func (o *Offscreen) fill(td *pixel.TrianglesData, pic *pixel.PictureData) {
+	height := o.bounds.H()
+	for i := 0; i+2 < len(*td); i += 3 {
+		v := [3]struct {
+			pos pixel.Vec
+			pixel.RGBA
+			pic       pixel.Vec
+			intensity float64
+		}{}
+		for j := range v {
+			d := (*td)[i+j]
+			// image rows go from the top
+			v[j].pos = pixel.V(d.Position.X-o.bounds.Min.X, height-(d.Position.Y-o.bounds.Min.Y))
+			v[j].RGBA = d.Color
+			v[j].pic = d.Picture
+			v[j].intensity = d.Intensity
+		}
+		area := cross(v[0].pos, v[1].pos, v[2].pos)
+		if area == 0 {
+			continue
+		}
+		minX := math.Max(0, math.Floor(math.Min(v[0].pos.X, math.Min(v[1].pos.X, v[2].pos.X))))
+		maxX := math.Min(float64(o.img.Rect.Dx()), math.Ceil(math.Max(v[0].pos.X, math.Max(v[1].pos.X, v[2].pos.X))))
+		minY := math.Max(0, math.Floor(math.Min(v[0].pos.Y, math.Min(v[1].pos.Y, v[2].pos.Y))))
+		maxY := math.Min(float64(o.img.Rect.Dy()), math.Ceil(math.Max(v[0].pos.Y, math.Max(v[1].pos.Y, v[2].pos.Y))))
+		for y := minY; y < maxY; y++ {
+			for x := minX; x < maxX; x++ {
+				p := pixel.V(x+0.5, y+0.5)
+				// barycentric coordinates
+				w0 := cross(v[1].pos, v[2].pos, p) / area
+				w1 := cross(v[2].pos, v[0].pos, p) / area
+				w2 := 1 - w0 - w1
+				if w0 < 0 || w1 < 0 || w2 < 0 {
+					continue
+				}
+				c := v[0].RGBA.Scaled(w0).Add(v[1].RGBA.Scaled(w1)).Add(v[2].RGBA.Scaled(w2))
+				if pic != nil {
+					intensity := w0*v[0].intensity + w1*v[1].intensity + w2*v[2].intensity
+					at := v[0].pic.Scaled(w0).Add(v[1].pic.Scaled(w1)).Add(v[2].pic.Scaled(w2))
+					tex := pic.Color(at)
+					c = c.Mul(pixel.Alpha(1 - intensity).Add(tex.Scaled(intensity)))
+				}
+				o.blend(int(x), int(y), c)
+			}
+		}
+	}
+}
+
+// Twice the signed area of triangle abc
+func cross(a, b, c pixel.Vec) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// Draw premultiplied color over the pixel
+func (o *Offscreen) blend(x, y int, c pixel.RGBA) {
+	i := o.img.PixOffset(x, y)
+	px := o.img.Pix[i : i+4]
+	channel := func(dst uint8, src float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(255, src*255+float64(dst)*(1-c.A)))))
+	}
+	px[0] = channel(px[0], c.R)
+	px[1] = channel(px[1], c.G)
+	px[2] = channel(px[2], c.B)
+	px[3] = channel(px[3], c.A)
+}
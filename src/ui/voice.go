@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/bunyk/fasolasi/src/config"
+	"github.com/bunyk/fasolasi/src/notes"
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+// VoiceNotes are given to the buttons in the order they are drawn, the easiest to play first
+var VoiceNotes = []string{"b", "a", "g", "c'", "d'", "e'", "f'", "g'", "a'", "f", "e", "d", "c", "b'", "c''"}
+
+// Voice control presses buttons when their notes are played
+type voiceState struct {
+	pitch func() float64 // nil when voice control is off
+	heard string         // name of the note heard
+	since time.Time      // when it started
+	fired bool           // the note heard already pressed something, so it should end before the next one
+	next  int            // index in VoiceNotes for the next button
+	now   func() time.Time
+}
+
+var voice voiceState
+
+// SetVoice turns on voice control, with pitch of the sound heard. Nil turns it off.
+func SetVoice(pitch func() float64) {
+	voice = voiceState{pitch: pitch, fired: true, now: time.Now}
+}
+
+// IgnoreVoice of this frame, for example when the game plays sound itself. Call it after Prepare.
+func IgnoreVoice() {
+	voice.fired = true
+}
+
+func prepareVoice() {
+	voice.next = 0
+	if voice.pitch == nil {
+		return
+	}
+	if p, _ := notes.GuessNote(voice.pitch()); p.Name != voice.heard {
+		voice.heard, voice.since, voice.fired = p.Name, voice.now(), false
+	}
+}
+
+// Note of the next button, or "" when voice control is off, or notes are over
+func voiceNote() string {
+	if voice.pitch == nil || voice.next >= len(VoiceNotes) {
+		return ""
+	}
+	voice.next++
+	return VoiceNotes[voice.next-1]
+}
+
+// Width of the space beside buttons, where their notes are shown
+const voiceLabelWidth = 60.0
+
+// VoiceMargin is the space needed beside the button for its note, 0 when voice control is off
+func VoiceMargin() float64 {
+	if voice.pitch == nil {
+		return 0
+	}
+	return voiceLabelWidth
+}
+
+// Tells if the note was played long enough to press the button, and draws it on the left or right side of the button
+func voiced(win Window, note string, location pixel.Rect, right bool) bool {
+	if note == "" {
+		return false
+	}
+	playing := voice.heard == note && !voice.fired
+	col := colornames.Dimgray
+	if playing {
+		col = config.FocusColor
+	}
+	at := pixel.R(location.Min.X-voiceLabelWidth, location.Min.Y, location.Min.X-10, location.Max.Y)
+	if right {
+		at = pixel.R(location.Max.X+10, location.Min.Y, location.Max.X+voiceLabelWidth, location.Max.Y)
+	}
+	Label(win, at, note, col)
+	if playing && voice.now().Sub(voice.since) >= config.VoiceHoldTime {
+		voice.fired = true
+		return true
+	}
+	return false
+}
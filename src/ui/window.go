@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"image/color"
+
+	"github.com/faiface/pixel"
+)
+
+// Canvas is something scenes could draw on: window, or off-screen image
+type Canvas interface {
+	pixel.Target
+	Bounds() pixel.Rect
+	Clear(c color.Color)
+}
+
+// Key of the keyboard or button of the mouse, numbered like in GLFW
+type Key int
+
+const (
+	MouseButtonLeft Key = 0
+	KeySpace        Key = 32
+	KeyA            Key = 65
+	KeyD            Key = 68
+	KeyF            Key = 70
+	KeyS            Key = 83
+	KeyEscape       Key = 256
+	KeyEnter        Key = 257
+	KeyTab          Key = 258
+	KeyBackspace    Key = 259
+	KeyRight        Key = 262
+	KeyLeft         Key = 263
+	KeyDown         Key = 264
+	KeyUp           Key = 265
+	KeyPageUp       Key = 266
+	KeyPageDown     Key = 267
+	KeyF3           Key = 292
+	KeyF12          Key = 301
+	KeyLeftShift    Key = 340
+	KeyRightShift   Key = 344
+)
+
+// Window is a canvas with input, like pixelgl.Window
+type Window interface {
+	Canvas
+	MousePosition() pixel.Vec
+	MouseScroll() pixel.Vec
+	Pressed(k Key) bool
+	JustPressed(k Key) bool
+	Repeated(k Key) bool
+	Typed() string
+	Update()
+	SetClosed(closed bool)
+}